@@ -0,0 +1,486 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Type is the static type of an expression or a variable.
+type Type uint8
+
+const (
+	// Any is the type of values which can't be known before evaluation
+	Any Type = iota
+	Bool
+	Number
+	String
+	Time
+	Array
+	Object
+)
+
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Time:
+		return "time"
+	case Array:
+		return "array"
+	case Object:
+		return "object"
+	}
+	return "any"
+}
+
+// TypeOf returns the type of a value, Any for nil and unsupported types.
+func TypeOf(value interface{}) Type {
+	switch value.(type) {
+	case nil:
+		return Any
+	case bool:
+		return Bool
+	case float64:
+		return Number
+	case string:
+		return String
+	case time.Time:
+		return Time
+	case []interface{}:
+		return Array
+	case map[string]interface{}:
+		return Object
+	}
+	return typeOfReflect(reflect.TypeOf(value))
+}
+
+func typeOfReflect(t reflect.Type) Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return Time
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return Number
+	case reflect.String:
+		return String
+	case reflect.Slice, reflect.Array:
+		return Array
+	case reflect.Map, reflect.Struct:
+		return Object
+	}
+	return Any
+}
+
+// sample returns a value of type t, used to evaluate operators statically.
+func (t Type) sample() interface{} {
+	switch t {
+	case Bool:
+		return true
+	case Number:
+		return 1.
+	case String:
+		return "a"
+	case Time:
+		return time.Time{}
+	case Array:
+		return []interface{}{}
+	case Object:
+		return map[string]interface{}{}
+	}
+	return nil
+}
+
+// Schema declares the variables of expressions by path, e.g. "user.age": Number.
+// Fields of a variable declared as Object, Array or Any are not checked.
+// A variable which is not declared itself but has declared fields is an Object.
+type Schema map[string]Type
+
+func (s Schema) lookup(path []string) (Type, error) {
+	for i := len(path); i > 0; i-- {
+		t, ok := s[strings.Join(path[:i], ".")]
+		if !ok {
+			continue
+		}
+		if i == len(path) {
+			return t, nil
+		}
+		switch t {
+		case Any, Array, Object:
+			return Any, nil
+		}
+		return Any, fmt.Errorf("%s is %s and has no field %s", strings.Join(path[:i], "."), t, path[i])
+	}
+	prefix := strings.Join(path, ".") + "."
+	for k := range s {
+		if strings.HasPrefix(k, prefix) {
+			return Object, nil
+		}
+	}
+	return Any, fmt.Errorf("unknown variable %s", strings.Join(path, "."))
+}
+
+// declares reports whether the root variable is declared, itself or by its fields
+func (s Schema) declares(root string) bool {
+	for k := range s {
+		if k == root || strings.HasPrefix(k, root+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Position of a token in an expression. Offset counts bytes from 0, Line and Column from 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (pos Position) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// TypeError is a type or schema error found by Analyze.
+type TypeError struct {
+	Position
+	Expression string
+	Message    string
+	// Closest variables, functions and constants, for unknown identifiers
+	Suggestions []string
+}
+
+func (err *TypeError) Error() string {
+	return err.Position.String() + ": " + err.Message + didYouMean(err.Suggestions)
+}
+
+// Analysis describes an expression without evaluating it.
+type Analysis struct {
+	// Static type of the result, Any if it depends on the parameter
+	Type Type
+	// Paths of the referenced variables, sorted, e.g. "user.age".
+	// Dynamic keys, like the k of a[k], are written *.
+	Variables []string
+	// Names of the called functions, sorted
+	Functions []string
+	// Type errors and, with a schema, unknown variables and functions
+	Errors []*TypeError
+}
+
+// Err returns the first error, nil if the expression is valid.
+func (a *Analysis) Err() error {
+	if len(a.Errors) == 0 {
+		return nil
+	}
+	return a.Errors[0]
+}
+
+// Analyze parses an expression and checks the types of its operators and
+// function calls. With a schema, variables are typed and validated against it,
+// without one they are of type Any.
+// The error is returned for syntax errors, type errors are in Analysis.Errors.
+func (l Language) Analyze(expression string, schema Schema) (*Analysis, error) {
+	a := &analyzer{
+		expression: expression,
+		names:      l.names(),
+		schema:     schema,
+		variables:  map[string]struct{}{},
+		functions:  map[string]struct{}{},
+	}
+	if _, err := l.parse(expression, a, false); err != nil {
+		return nil, err
+	}
+	result := &Analysis{Errors: a.errors}
+	if len(a.types) > 0 {
+		result.Type = a.types[len(a.types)-1]
+	}
+	for v := range a.variables {
+		result.Variables = append(result.Variables, v)
+	}
+	for f := range a.functions {
+		result.Functions = append(result.Functions, f)
+	}
+	sort.Strings(result.Variables)
+	sort.Strings(result.Functions)
+	return result, nil
+}
+
+// analyzer follows the parser with a stack of types: every parsed expression
+// pushes its type. Prefixes which know their type call declare, for the
+// others the type is the one of their only sub expression or Any.
+type analyzer struct {
+	expression  string
+	names       []string
+	schema      Schema
+	types       []Type
+	declared    Type
+	hasDeclared bool
+	scopes      []string
+	variables   map[string]struct{}
+	functions   map[string]struct{}
+	errors      []*TypeError
+}
+
+func (a *analyzer) mark() int {
+	return len(a.types)
+}
+
+func (a *analyzer) push(t Type) {
+	a.types = append(a.types, t)
+}
+
+func (a *analyzer) pop() Type {
+	if len(a.types) == 0 {
+		return Any
+	}
+	t := a.types[len(a.types)-1]
+	a.types = a.types[:len(a.types)-1]
+	return t
+}
+
+// since returns the types pushed after mark
+func (a *analyzer) since(mark int) []Type {
+	if mark > len(a.types) {
+		return nil
+	}
+	return append([]Type(nil), a.types[mark:]...)
+}
+
+func (a *analyzer) declare(t Type) {
+	a.declared, a.hasDeclared = t, true
+}
+
+// settle replaces the types pushed since mark by the type of the expression
+func (a *analyzer) settle(mark int) {
+	t := Any
+	switch {
+	case a.hasDeclared:
+		t = a.declared
+	case len(a.types) == mark+1:
+		t = a.types[mark]
+	}
+	if mark < len(a.types) {
+		a.types = a.types[:mark]
+	}
+	a.push(t)
+	a.hasDeclared = false
+}
+
+func (a *analyzer) errorf(pos Position, format string, args ...interface{}) {
+	a.errors = append(a.errors, &TypeError{Position: pos, Expression: a.expression, Message: fmt.Sprintf(format, args...)})
+}
+
+func (a *analyzer) inScope(name string) bool {
+	for _, s := range a.scopes {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// variable records a variable path and returns its type
+func (a *analyzer) variable(pos Position, path []string) Type {
+	if a.inScope(path[0]) {
+		return Any
+	}
+	a.variables[strings.Join(path, ".")] = struct{}{}
+	if a.schema == nil {
+		return Any
+	}
+	t, err := a.schema.lookup(path)
+	if err != nil {
+		a.errorf(pos, "%s", err)
+		candidates := append([]string(nil), a.names...)
+		for k := range a.schema {
+			candidates = append(candidates, k)
+		}
+		a.errors[len(a.errors)-1].Suggestions = suggest(strings.Join(path, "."), candidates)
+	}
+	return t
+}
+
+// call records the call of a variable, with a schema the variable must be
+// declared or be a field of one. A single name which isn't declared is
+// reported as an unknown function.
+func (a *analyzer) callVariable(pos Position, path []string, suggestions []string) {
+	if len(path) > 1 || a.schema == nil || a.inScope(path[0]) || a.schema.declares(path[0]) {
+		a.variable(pos, path)
+		return
+	}
+	a.variables[path[0]] = struct{}{}
+	a.errorf(pos, "unknown function %s", path[0])
+	a.errors[len(a.errors)-1].Suggestions = suggestions
+}
+
+// infix returns the type of x name y
+func (a *analyzer) infix(pos Position, name string, op operator, x, y Type) Type {
+	switch op := op.(type) {
+	case *infix:
+		return a.infixType(pos, name, op, x, y)
+	case directInfix:
+		ev, err := op.infixBuilder(constant(x.sample()), constant(y.sample()))
+		if err == nil {
+			var v interface{}
+			if v, err = ev(context.Background(), nil); err == nil {
+				return TypeOf(v)
+			}
+		}
+		if x != Any && y != Any {
+			a.errorf(pos, "invalid operation: %s %s %s", x, name, y)
+		}
+	}
+	return Any
+}
+
+// The typed variants of an operator are tried in the order the evaluation
+// tries them. Statically, bools are not converted to numbers or strings.
+func (a *analyzer) infixType(pos Position, name string, op *infix, x, y Type) Type {
+	accepts := func(types ...Type) bool {
+		okX, okY := x == Any, y == Any
+		for _, t := range types {
+			okX = okX || x == t
+			okY = okY || y == t
+		}
+		return okX && okY
+	}
+	var results []Type
+	var err error
+	if op.number != nil && accepts(Number) {
+		results = append(results, resultType(op.number(1, 1)))
+	}
+	if op.boolean != nil && accepts(Bool) {
+		results = append(results, resultType(op.boolean(true, true)))
+	}
+	if op.text != nil && accepts(String, Number) {
+		results = append(results, resultType(op.text("a", "a")))
+	}
+	if op.arbitrary != nil {
+		var v interface{}
+		if v, err = op.arbitrary(x.sample(), y.sample()); err == nil {
+			results = append(results, TypeOf(v))
+		} else if x == Any || y == Any {
+			results = append(results, Any)
+		}
+	}
+	if len(results) == 0 {
+		if err != nil {
+			a.errorf(pos, "invalid operation: %s %s %s: %s", x, name, y, err)
+		} else {
+			a.errorf(pos, "invalid operation: %s %s %s", x, name, y)
+		}
+		return Any
+	}
+	if x != Any && y != Any {
+		return results[0]
+	}
+	for _, t := range results[1:] {
+		if t != results[0] {
+			return Any
+		}
+	}
+	return results[0]
+}
+
+func resultType(v interface{}, err error) Type {
+	if err != nil {
+		return Any
+	}
+	return TypeOf(v)
+}
+
+// prefix returns the type of the prefix operator applied to x
+func (a *analyzer) prefix(pos Position, name string, e Evaluable, x Type) Type {
+	v, err := e(context.Background(), x.sample())
+	if err != nil {
+		if x != Any {
+			a.errorf(pos, "invalid operation: %s%s", name, x)
+		}
+		return Any
+	}
+	return TypeOf(v)
+}
+
+// signature of a function for the analysis, max < 0 for variadic functions
+type signature struct {
+	min, max int
+	params   []Type
+	result   Type
+}
+
+func newSignature(result Type, min, max int, params ...Type) *signature {
+	return &signature{min: min, max: max, params: params, result: result}
+}
+
+func signatureOf(function interface{}) *signature {
+	switch function.(type) {
+	case func(arguments ...interface{}) (interface{}, error),
+		func(ctx context.Context, arguments ...interface{}) (interface{}, error):
+		return newSignature(Any, 0, -1)
+	}
+	t := reflect.TypeOf(function)
+	if t == nil || t.Kind() != reflect.Func {
+		return newSignature(Any, 0, -1)
+	}
+	s := &signature{}
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if i == 0 && in == contextType {
+			continue
+		}
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			in = in.Elem()
+		}
+		s.params = append(s.params, typeOfReflect(in))
+	}
+	s.min, s.max = len(s.params), len(s.params)
+	if t.IsVariadic() {
+		s.min, s.max = s.min-1, -1
+	}
+	out := t.NumOut()
+	if out > 0 && t.Out(out-1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
+		out--
+	}
+	if out == 1 {
+		s.result = typeOfReflect(t.Out(0))
+	}
+	return s
+}
+
+// call checks the arguments of a function call and returns its type
+func (a *analyzer) call(pos Position, name string, s *signature, args []Type) Type {
+	a.functions[name] = struct{}{}
+	switch {
+	case len(args) < s.min:
+		a.errorf(pos, "%s() expects at least %d arguments but got %d", name, s.min, len(args))
+	case s.max >= 0 && len(args) > s.max:
+		a.errorf(pos, "%s() expects at most %d arguments but got %d", name, s.max, len(args))
+	}
+	for i, arg := range args {
+		if len(s.params) == 0 {
+			break
+		}
+		param := s.params[len(s.params)-1]
+		if i < len(s.params) {
+			param = s.params[i]
+		}
+		if param != Any && arg != Any && param != arg {
+			a.errorf(pos, "%s() expects %s as argument %d but got %s", name, param, i+1, arg)
+		}
+	}
+	return s.result
+}
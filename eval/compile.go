@@ -0,0 +1,206 @@
+package eval
+
+import (
+	"context"
+	"reflect"
+)
+
+// numEvaluable evaluates an expression to a float64 without boxing it.
+// ok is false when the value is not a number, the caller then evaluates the
+// Evaluable of the expression instead, which also reports the errors.
+type numEvaluable func(c context.Context, parameter interface{}) (f float64, ok bool, err error)
+
+// Compile parses an expression like NewEvaluable, for Evaluables which are
+// evaluated many times. On top of folding constant operations it folds
+// constant conditions, short circuits and arrays, resolves the keys of
+// variables once, and evaluates numeric operations without boxing numbers.
+// The results are the ones of NewEvaluable. Constant arrays are shared by
+// all the evaluations, functions must not modify them.
+func (l Language) Compile(expression string) (Evaluable, error) {
+	return l.parse(expression, nil, true)
+}
+
+func (p *Parser) declareNum(num numEvaluable) {
+	if p.optimize && num != nil {
+		p.num, p.numDepth = num, p.depth
+	}
+}
+
+func (p *Parser) optimized(op *infix) *infix {
+	if p.optimize && p.limits == nil {
+		return op
+	}
+	return nil
+}
+
+func constNum(v interface{}) numEvaluable {
+	f, ok := v.(float64)
+	if !ok {
+		return nil
+	}
+	return func(c context.Context, parameter interface{}) (float64, bool, error) {
+		return f, true, nil
+	}
+}
+
+func constValues(evals []Evaluable) ([]interface{}, bool) {
+	vs := make([]interface{}, len(evals))
+	for i, e := range evals {
+		if !e.IsConst() {
+			return nil, false
+		}
+		v, err := e(context.Background(), nil)
+		if err != nil {
+			return nil, false
+		}
+		vs[i] = v
+	}
+	return vs, true
+}
+
+// compile optimizes x op y, generic is the Evaluable built by the operator.
+func (op *infix) compile(x, y stage, generic Evaluable) (Evaluable, numEvaluable) {
+	if op.shortCircuit != nil && x.IsConst() {
+		a, err := x.Evaluable(context.Background(), nil)
+		if err == nil {
+			if r, ok := op.shortCircuit(a); ok {
+				return constant(r), nil
+			}
+		}
+	}
+	if x.num == nil || y.num == nil {
+		return generic, nil
+	}
+	nx, ny := x.num, y.num
+	switch {
+	case op.float != nil:
+		f := op.float
+		num := func(c context.Context, v interface{}) (float64, bool, error) {
+			a, ok, err := nx(c, v)
+			if !ok || err != nil {
+				return 0, false, err
+			}
+			b, ok, err := ny(c, v)
+			if !ok || err != nil {
+				return 0, false, err
+			}
+			return f(a, b), true, nil
+		}
+		return func(c context.Context, v interface{}) (interface{}, error) {
+			r, ok, err := num(c, v)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return r, nil
+			}
+			return generic(c, v)
+		}, num
+	case op.compare != nil:
+		f := op.compare
+		return func(c context.Context, v interface{}) (interface{}, error) {
+			a, ok, err := nx(c, v)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				b, ok, err := ny(c, v)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					return f(a, b), nil
+				}
+			}
+			return generic(c, v)
+		}, nil
+	}
+	return generic, nil
+}
+
+// compileVariable resolves a variable of constant keys, it returns a nil
+// Evaluable if a key isn't constant.
+func compileVariable(path Evaluables) (Evaluable, numEvaluable) {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		if !k.IsConst() {
+			return nil, nil
+		}
+		s, err := k.EvalString(context.Background(), nil)
+		if err != nil {
+			return nil, nil
+		}
+		keys[i] = s
+	}
+	parent, last := keys[:len(keys)-1], keys[len(keys)-1]
+	eval := func(c context.Context, v interface{}) (interface{}, error) {
+		return selectPath(c, keys, v)
+	}
+	num := func(c context.Context, v interface{}) (float64, bool, error) {
+		o, err := selectPath(c, parent, v)
+		if err != nil {
+			return 0, false, nil
+		}
+		switch o := o.(type) {
+		case map[string]interface{}:
+			f, ok := numberValue(o[last])
+			return f, ok, nil
+		case Selector, map[interface{}]interface{}, []interface{}:
+			r, err := selectPath(c, keys[len(keys)-1:], o)
+			if err != nil {
+				return 0, false, nil
+			}
+			f, ok := numberValue(r)
+			return f, ok, nil
+		}
+		// struct fields are read without boxing them
+		rv := reflect.ValueOf(o)
+		if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct ||
+			rv.Kind() == reflect.Struct {
+			if m := lookupMember(rv.Type(), last); len(m.field) == 1 {
+				return reflectNumber(resolvePotentialPointer(rv).Field(m.field[0]))
+			}
+			return 0, false, nil
+		}
+		r, ok := reflectSelect(last, o)
+		if !ok {
+			return 0, false, nil
+		}
+		f, ok := numberValue(r)
+		return f, ok, nil
+	}
+	return eval, num
+}
+
+func reflectNumber(v reflect.Value) (float64, bool, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true, nil
+	}
+	return 0, false, nil
+}
+
+// numberValue converts numbers to float64, strings and bools are not numbers
+// here since operators may treat them as text or bools.
+func numberValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case nil, string, bool:
+		return 0, false
+	}
+	f, ok, _ := reflectNumber(reflect.ValueOf(v))
+	return f, ok
+}
@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"context"
+	"testing"
+)
+
+type benchOrder struct {
+	Amount float64
+	Qty    int
+	Level  int
+}
+
+var compileBenchmarks = []struct {
+	name       string
+	expression string
+	parameter  interface{}
+}{
+	{
+		name:       "Arithmetic",
+		expression: `(a + b) * c - a / 2 > 10`,
+		parameter:  map[string]interface{}{"a": 3, "b": 4.5, "c": 2},
+	},
+	{
+		name:       "Struct",
+		expression: `order.Amount * order.Qty > 300 && order.Level >= 2`,
+		parameter:  map[string]interface{}{"order": &benchOrder{Amount: 120, Qty: 3, Level: 2}},
+	},
+	{
+		name:       "Constants",
+		expression: `x * (60 * 60 * 24) + (1 + 2) * 3 > 100 && ("a" + "b") == s`,
+		parameter:  map[string]interface{}{"x": 2, "s": "ab"},
+	},
+}
+
+// BenchmarkEvaluate parses the expression at each evaluation
+func BenchmarkEvaluate(b *testing.B) {
+	lang := Full()
+	for _, bm := range compileBenchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := lang.Evaluate(bm.expression, bm.parameter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkNewEvaluable evaluates the expression parsed once, without optimizations
+func BenchmarkNewEvaluable(b *testing.B) {
+	lang := Full()
+	ctx := context.Background()
+	for _, bm := range compileBenchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			e, err := lang.NewEvaluable(bm.expression)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := e(ctx, bm.parameter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCompile evaluates the expression compiled once
+func BenchmarkCompile(b *testing.B) {
+	lang := Full()
+	ctx := context.Background()
+	for _, bm := range compileBenchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			e, err := lang.Compile(bm.expression)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := e(ctx, bm.parameter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileMatchesEvaluate(t *testing.T) {
+	lang := Full()
+	for _, bm := range compileBenchmarks {
+		want, err := lang.Evaluate(bm.expression, bm.parameter)
+		if err != nil {
+			t.Fatalf("%s: %v", bm.name, err)
+		}
+		e, err := lang.Compile(bm.expression)
+		if err != nil {
+			t.Fatalf("%s: %v", bm.name, err)
+		}
+		got, err := e(context.Background(), bm.parameter)
+		if err != nil || got != want {
+			t.Errorf("%s: Compile gives %v, %v, Evaluate gives %v", bm.name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/scanner"
+	"unicode/utf8"
+)
+
+// ParseError is the syntax error of an expression returned by NewEvaluable,
+// Compile and Analyze.
+type ParseError struct {
+	Position
+	Expression string
+	// Offending token, empty at the end of the expression
+	Token   string
+	Message string
+	// Closest names defined by the language, for unknown operators
+	Suggestions []string
+	err         error
+}
+
+func (err *ParseError) Error() string {
+	near := "at end of expression"
+	if err.Token != "" {
+		near = fmt.Sprintf("near %q", err.Token)
+	}
+	return fmt.Sprintf("parsing error at %s %s: %s%s", err.Position, near, err.Message, didYouMean(err.Suggestions))
+}
+
+// Unwrap returns the error of the parser, a *LimitError when the expression is nested too deep.
+func (err *ParseError) Unwrap() error {
+	return err.err
+}
+
+// Snippet returns the line of the error and a caret pointing at the offending token:
+//
+//	score >> 60 &&& ok
+//	            ^
+func (err *ParseError) Snippet() string {
+	return snippet(err.Expression, err.Position)
+}
+
+// Snippet returns the line of the error and a caret pointing at it, like ParseError.Snippet.
+func (err *TypeError) Snippet() string {
+	return snippet(err.Expression, err.Position)
+}
+
+func snippet(expression string, pos Position) string {
+	lines := strings.Split(expression, "\n")
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+	caret := strings.Builder{}
+	for i, r := range line {
+		if utf8.RuneCountInString(line[:i]) >= pos.Column-1 {
+			break
+		}
+		// tabs are kept so that the caret lines up with the line
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	return line + "\n" + caret.String() + "^"
+}
+
+func didYouMean(suggestions []string) string {
+	switch len(suggestions) {
+	case 0:
+		return ""
+	case 1:
+		return ", did you mean " + suggestions[0] + "?"
+	}
+	return ", did you mean " + strings.Join(suggestions[:len(suggestions)-1], ", ") + " or " + suggestions[len(suggestions)-1] + "?"
+}
+
+type unknownOperator struct {
+	name string
+	pos  Position
+}
+
+func (err unknownOperator) Error() string {
+	return "unknown operator " + err.name
+}
+
+// parseError adds the position and the offending token to an error of the parser
+func (p *Parser) parseError(err error) *ParseError {
+	e := &ParseError{Position: p.pos(), Expression: p.expression, Message: err.Error(), err: err}
+	var op unknownOperator
+	if errors.As(err, &op) {
+		e.Position, e.Token = op.pos, op.name
+		operators := make([]string, 0, len(p.operators))
+		for name := range p.operators {
+			operators = append(operators, name)
+		}
+		e.Suggestions = suggest(op.name, operators)
+		return e
+	}
+	// Next() invalidates the position of the token, the error is then at the current position
+	if e.Line == 0 {
+		pos := p.scanner.Pos()
+		e.Position = Position{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+	}
+	if p.lastScan != scanner.EOF {
+		e.Token = p.TokenText()
+	}
+	return e
+}
+
+// unknownParameter is the error of a variable which the parameter doesn't have
+type unknownParameter struct {
+	path []string
+	// Closest functions and constants, for variables of a single name
+	suggestions []string
+}
+
+func (err *unknownParameter) Error() string {
+	return "unknown parameter " + strings.Join(err.path, ".") + didYouMean(err.suggestions)
+}
+
+// suggestNames completes the error of an unknown variable of a single name
+// with the closest functions and constants, looked up only when it fails
+func (p *Parser) suggestNames(path []string, e Evaluable) Evaluable {
+	if len(path) != 1 {
+		return e
+	}
+	l := p.Language
+	return func(c context.Context, v interface{}) (interface{}, error) {
+		r, err := e(c, v)
+		if u, ok := err.(*unknownParameter); ok && len(u.path) == 1 && u.suggestions == nil {
+			u.suggestions = suggest(u.path[0], l.names())
+		}
+		return r, err
+	}
+}
+
+// names returns the functions and constants of the language
+func (l Language) names() []string {
+	var names []string
+	for key := range l.prefixes {
+		if name, ok := key.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// suggest returns up to 3 candidates closest to name, ignoring case,
+// which are a few edits away from it.
+func suggest(name string, candidates []string) []string {
+	type match struct {
+		name     string
+		distance int
+	}
+	max := utf8.RuneCountInString(name) / 3
+	if max < 1 {
+		max = 1
+	}
+	var matches []match
+	for _, c := range candidates {
+		if c == name {
+			continue
+		}
+		if d := distance(strings.ToLower(name), strings.ToLower(c)); d <= max {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+	var names []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		names = append(names, matches[i].name)
+	}
+	return names
+}
+
+// distance is the Levenshtein distance of a and b in runes
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cur := row[j]
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = minInt(minInt(row[j]+1, row[j-1]+1), prev+cost)
+			prev = cur
+		}
+	}
+	return row[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
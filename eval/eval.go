@@ -55,6 +55,12 @@ func Base() Language {
 	return base
 }
 
+// Stdlib returns the standard functions, it is not part of Full and is
+// added as an extension: Full(Stdlib()).
+func Stdlib() Language {
+	return stdlib
+}
+
 var full = NewLanguage(arithmetic, bitmask, text, propositionalLogic, ljson,
 
 	InfixOperator("in", inArray),
@@ -71,59 +77,65 @@ var full = NewLanguage(arithmetic, bitmask, text, propositionalLogic, ljson,
 
 	PostfixOperator("?", parseIf),
 
-	Function("date", func(arguments ...interface{}) (interface{}, error) {
-		if len(arguments) != 1 {
-			return nil, fmt.Errorf("date() expects exactly one string argument")
-		}
-		s, ok := arguments[0].(string)
-		if !ok {
-			return nil, fmt.Errorf("date() expects exactly one string argument")
-		}
-		for _, format := range [...]string{
-			time.ANSIC,
-			time.UnixDate,
-			time.RubyDate,
-			time.Kitchen,
-			time.RFC3339,
-			time.RFC3339Nano,
-			"2006-01-02",                         // RFC 3339
-			"2006-01-02 15:04",                   // RFC 3339 with minutes
-			"2006-01-02 15:04:05",                // RFC 3339 with seconds
-			"2006-01-02 15:04:05-07:00",          // RFC 3339 with seconds and timezone
-			"2006-01-02T15Z0700",                 // ISO8601 with hour
-			"2006-01-02T15:04Z0700",              // ISO8601 with minutes
-			"2006-01-02T15:04:05Z0700",           // ISO8601 with seconds
-			"2006-01-02T15:04:05.999999999Z0700", // ISO8601 with nanoseconds
-		} {
-			ret, err := time.ParseInLocation(format, s, time.Local)
-			if err == nil {
-				return ret, nil
-			}
-		}
-		return nil, fmt.Errorf("date() could not parse %s", s)
-	}),
+	Function("date", date),
 )
 
+func date(arguments ...interface{}) (interface{}, error) {
+	if len(arguments) != 1 {
+		return nil, fmt.Errorf("date() expects exactly one string argument")
+	}
+	s, ok := arguments[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("date() expects exactly one string argument")
+	}
+	return parseDate(s)
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, format := range [...]string{
+		time.ANSIC,
+		time.UnixDate,
+		time.RubyDate,
+		time.Kitchen,
+		time.RFC3339,
+		time.RFC3339Nano,
+		"2006-01-02",                         // RFC 3339
+		"2006-01-02 15:04",                   // RFC 3339 with minutes
+		"2006-01-02 15:04:05",                // RFC 3339 with seconds
+		"2006-01-02 15:04:05-07:00",          // RFC 3339 with seconds and timezone
+		"2006-01-02T15Z0700",                 // ISO8601 with hour
+		"2006-01-02T15:04Z0700",              // ISO8601 with minutes
+		"2006-01-02T15:04:05Z0700",           // ISO8601 with seconds
+		"2006-01-02T15:04:05.999999999Z0700", // ISO8601 with nanoseconds
+	} {
+		ret, err := time.ParseInLocation(format, s, time.Local)
+		if err == nil {
+			return ret, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date() could not parse %s", s)
+}
+
 var ljson = NewLanguage(
 	PrefixExtension('[', parseJSONArray),
 	PrefixExtension('{', parseJSONObject),
 )
 
 var arithmetic = NewLanguage(
-	InfixNumberOperator("+", func(a, b float64) (interface{}, error) { return a + b, nil }),
-	InfixNumberOperator("-", func(a, b float64) (interface{}, error) { return a - b, nil }),
-	InfixNumberOperator("*", func(a, b float64) (interface{}, error) { return a * b, nil }),
-	InfixNumberOperator("/", func(a, b float64) (interface{}, error) { return a / b, nil }),
-	InfixNumberOperator("%", func(a, b float64) (interface{}, error) { return math.Mod(a, b), nil }),
-	InfixNumberOperator("**", func(a, b float64) (interface{}, error) { return math.Pow(a, b), nil }),
-
-	InfixNumberOperator(">", func(a, b float64) (interface{}, error) { return a > b, nil }),
-	InfixNumberOperator(">=", func(a, b float64) (interface{}, error) { return a >= b, nil }),
-	InfixNumberOperator("<", func(a, b float64) (interface{}, error) { return a < b, nil }),
-	InfixNumberOperator("<=", func(a, b float64) (interface{}, error) { return a <= b, nil }),
-
-	InfixNumberOperator("==", func(a, b float64) (interface{}, error) { return a == b, nil }),
-	InfixNumberOperator("!=", func(a, b float64) (interface{}, error) { return a != b, nil }),
+	infixFloatOperator("+", func(a, b float64) float64 { return a + b }),
+	infixFloatOperator("-", func(a, b float64) float64 { return a - b }),
+	infixFloatOperator("*", func(a, b float64) float64 { return a * b }),
+	infixFloatOperator("/", func(a, b float64) float64 { return a / b }),
+	infixFloatOperator("%", func(a, b float64) float64 { return math.Mod(a, b) }),
+	infixFloatOperator("**", func(a, b float64) float64 { return math.Pow(a, b) }),
+
+	infixCompareOperator(">", func(a, b float64) bool { return a > b }),
+	infixCompareOperator(">=", func(a, b float64) bool { return a >= b }),
+	infixCompareOperator("<", func(a, b float64) bool { return a < b }),
+	infixCompareOperator("<=", func(a, b float64) bool { return a <= b }),
+
+	infixCompareOperator("==", func(a, b float64) bool { return a == b }),
+	infixCompareOperator("!=", func(a, b float64) bool { return a != b }),
 
 	base,
 )
@@ -6,7 +6,7 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
-	"strings"
+	"sync"
 )
 
 type Evaluable func(c context.Context, parameter interface{}) (interface{}, error)
@@ -62,6 +62,10 @@ func (*Parser) Const(value interface{}) Evaluable {
 	return constant(value)
 }
 
+// constant must not be inlined: IsConst compares the code pointer of the
+// closure, which differs for every function constant is inlined into.
+//
+//go:noinline
 func constant(value interface{}) Evaluable {
 	return func(c context.Context, v interface{}) (interface{}, error) {
 		return value, nil
@@ -89,35 +93,52 @@ func (evs Evaluables) EvalStrings(c context.Context, parameter interface{}) ([]s
 	return strs, nil
 }
 
+// Selector is implemented by parameters which resolve their own fields,
+// the variable a.b calls SelectEval(c, "a") and then selects b on the result.
+type Selector interface {
+	SelectEval(c context.Context, key string) (interface{}, error)
+}
+
 func variable(path Evaluables) Evaluable {
 	return func(c context.Context, v interface{}) (interface{}, error) {
 		keys, err := path.EvalStrings(c, v)
 		if err != nil {
 			return nil, err
 		}
-		for i, k := range keys {
-			switch o := v.(type) {
-			case map[interface{}]interface{}:
-				v = o[k]
-				continue
-			case map[string]interface{}:
-				v = o[k]
+		return selectPath(c, keys, v)
+	}
+}
+
+func selectPath(c context.Context, keys []string, v interface{}) (interface{}, error) {
+	var err error
+	for i, k := range keys {
+		switch o := v.(type) {
+		case Selector:
+			v, err = o.SelectEval(c, k)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case map[interface{}]interface{}:
+			v = o[k]
+			continue
+		case map[string]interface{}:
+			v = o[k]
+			continue
+		case []interface{}:
+			if i, err := strconv.Atoi(k); err == nil && i >= 0 && len(o) > i {
+				v = o[i]
 				continue
-			case []interface{}:
-				if i, err := strconv.Atoi(k); err == nil && i >= 0 && len(o) > i {
-					v = o[i]
-					continue
-				}
-			default:
-				var ok bool
-				v, ok = reflectSelect(k, o)
-				if !ok {
-					return nil, fmt.Errorf("unknown parameter %s", strings.Join(keys[:i+1], "."))
-				}
+			}
+		default:
+			var ok bool
+			v, ok = reflectSelect(k, o)
+			if !ok {
+				return nil, &unknownParameter{path: keys[:i+1]}
 			}
 		}
-		return v, nil
 	}
+	return v, nil
 }
 
 func reflectSelect(key string, value interface{}) (selection interface{}, ok bool) {
@@ -143,19 +164,51 @@ func reflectSelect(key string, value interface{}) (selection interface{}, ok boo
 			return vvElem.Interface(), true
 		}
 	case reflect.Struct:
-		field := vvElem.FieldByName(key)
-		if field.IsValid() {
-			return field.Interface(), true
+		member := lookupMember(vv.Type(), key)
+		if member.field != nil {
+			return vvElem.FieldByIndex(member.field).Interface(), true
 		}
-
-		method := vv.MethodByName(key)
-		if method.IsValid() {
-			return method.Interface(), true
+		if member.method >= 0 {
+			return vv.Method(member.method).Interface(), true
 		}
 	}
 	return nil, false
 }
 
+type memberKey struct {
+	t   reflect.Type
+	key string
+}
+
+// member of a struct type, the index of a field or of a method
+type member struct {
+	field  []int
+	method int
+}
+
+// members caches the struct fields and methods by type and name,
+// FieldByName walks all the fields of a struct on every call.
+var members sync.Map
+
+func lookupMember(t reflect.Type, key string) member {
+	k := memberKey{t, key}
+	if m, ok := members.Load(k); ok {
+		return m.(member)
+	}
+	m := member{method: -1}
+	elem := t
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if f, ok := elem.FieldByName(key); ok {
+		m.field = f.Index
+	} else if method, ok := t.MethodByName(key); ok {
+		m.method = method.Index
+	}
+	members.Store(k, m)
+	return m
+}
+
 func resolvePotentialPointer(value reflect.Value) reflect.Value {
 	if value.Kind() == reflect.Ptr {
 		return value.Elem()
@@ -175,8 +228,8 @@ func reflectConvertTo(k reflect.Kind, value string) (interface{}, bool) {
 	return nil, false
 }
 
-func (*Parser) callFunc(fun function, args ...Evaluable) Evaluable {
-	return func(c context.Context, v interface{}) (ret interface{}, err error) {
+func (p *Parser) callFunc(fun function, args ...Evaluable) Evaluable {
+	return p.limit(func(c context.Context, v interface{}) (ret interface{}, err error) {
 		a := make([]interface{}, len(args))
 		for i, arg := range args {
 			ai, err := arg(c, v)
@@ -186,14 +239,17 @@ func (*Parser) callFunc(fun function, args ...Evaluable) Evaluable {
 			a[i] = ai
 		}
 		return fun(c, a...)
-	}
+	})
 }
 
-func (*Parser) callEvaluable(fullname string, fun Evaluable, args ...Evaluable) Evaluable {
-	return func(c context.Context, v interface{}) (ret interface{}, err error) {
+func (p *Parser) callEvaluable(fullname string, suggestions []string, fun Evaluable, args ...Evaluable) Evaluable {
+	return p.limit(func(c context.Context, v interface{}) (ret interface{}, err error) {
 		f, err := fun(c, v)
 
 		if err != nil {
+			if u, ok := err.(*unknownParameter); ok && len(u.path) == 1 && u.suggestions == nil {
+				u.suggestions = suggestions
+			}
 			return nil, fmt.Errorf("could not call function: %v", err)
 		}
 
@@ -207,16 +263,19 @@ func (*Parser) callEvaluable(fullname string, fun Evaluable, args ...Evaluable)
 		ff := reflect.ValueOf(f)
 
 		if ff.Kind() != reflect.Func {
-			return nil, fmt.Errorf("could not call '%s' type %T", fullname, f)
+			return nil, fmt.Errorf("could not call '%s' type %T%s", fullname, f, didYouMean(suggestions))
 		}
 
-		a := make([]reflect.Value, len(args))
+		values := make([]interface{}, len(args))
 		for i := range args {
-			arg, err := args[i](c, v)
+			values[i], err = args[i](c, v)
 			if err != nil {
 				return nil, err
 			}
-			a[i] = reflect.ValueOf(arg)
+		}
+		a, err := createCallArguments(c, ff.Type(), values)
+		if err != nil {
+			return nil, fmt.Errorf("could not call '%s': %v", fullname, err)
 		}
 
 		rr := ff.Call(a)
@@ -242,7 +301,7 @@ func (*Parser) callEvaluable(fullname string, fun Evaluable, args ...Evaluable)
 		default:
 			return r, err
 		}
-	}
+	})
 }
 
 func (e Evaluable) IsConst() bool {
@@ -2,7 +2,9 @@ package eval
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 )
 
@@ -78,36 +80,117 @@ func toFunc(f interface{}) function {
 	}
 }
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 func createCallArguments(ctx context.Context, t reflect.Type, args []interface{}) ([]reflect.Value, error) {
 	variadic := t.IsVariadic()
 	numIn := t.NumIn()
 
-	if numIn > 0 {
-		thisFun := reflect.ValueOf(createCallArguments)
-		thisT := thisFun.Type()
-		if t.In(0) == thisT.In(0) {
-			args = append([]interface{}{ctx}, args...)
+	in := make([]reflect.Value, 0, len(args)+1)
+	first := 0
+	if numIn > 0 && t.In(0) == contextType {
+		if ctx == nil {
+			ctx = context.Background()
 		}
+		in = append(in, reflect.ValueOf(&ctx).Elem())
+		first = 1
 	}
 
-	if (!variadic && len(args) != numIn) || (variadic && len(args) < numIn-1) {
-		return nil, fmt.Errorf("invalid number of parameters")
+	expected := numIn - first
+	if variadic {
+		expected--
+		if len(args) < expected {
+			return nil, fmt.Errorf("invalid number of parameters: expected at least %d but got %d", expected, len(args))
+		}
+	} else if len(args) != expected {
+		return nil, fmt.Errorf("invalid number of parameters: expected %d but got %d", expected, len(args))
 	}
 
-	in := make([]reflect.Value, len(args))
 	var inType reflect.Type
 	for i, arg := range args {
-		if !variadic || i < numIn-1 {
-			inType = t.In(i)
-		} else if i == numIn-1 {
+		j := i + first
+		if !variadic || j < numIn-1 {
+			inType = t.In(j)
+		} else {
 			inType = t.In(numIn - 1).Elem()
 		}
-		argVal := reflect.ValueOf(arg)
-		if arg == nil || !argVal.Type().AssignableTo(inType) {
+		argVal, err := convertArgument(arg, inType)
+		if err == errArgumentType {
 			return nil, fmt.Errorf("expected type %s for parameter %d but got %T",
 				inType.String(), i, arg)
 		}
-		in[i] = argVal
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for parameter %d: %s", i, err)
+		}
+		in = append(in, argVal)
 	}
 	return in, nil
 }
+
+// errArgumentType is returned by convertArgument for values which can't be
+// converted to the parameter type at all
+var errArgumentType = errors.New("argument type mismatch")
+
+// convertArgument converts an evaluated value to the parameter type t.
+// Numbers are evaluated as float64, they are converted to any numeric type
+// as long as integers don't lose their fraction and the value fits the type.
+func convertArgument(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, errArgumentType
+	}
+	argVal := reflect.ValueOf(arg)
+	if argVal.Type().AssignableTo(t) {
+		return argVal, nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := convertToFloat(arg)
+		if !ok {
+			return reflect.Value{}, errArgumentType
+		}
+		if f != math.Trunc(f) {
+			return reflect.Value{}, fmt.Errorf("%v is not an integer", f)
+		}
+		// float64(math.MaxInt64) is 2^63, which doesn't fit
+		if f < math.MinInt64 || f >= math.MaxInt64 || reflect.Zero(t).OverflowInt(int64(f)) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %s", f, t)
+		}
+		return reflect.ValueOf(int64(f)).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := convertToFloat(arg)
+		if !ok {
+			return reflect.Value{}, errArgumentType
+		}
+		if f != math.Trunc(f) {
+			return reflect.Value{}, fmt.Errorf("%v is not an integer", f)
+		}
+		if f < 0 || f >= math.MaxUint64 || reflect.Zero(t).OverflowUint(uint64(f)) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %s", f, t)
+		}
+		return reflect.ValueOf(uint64(f)).Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := convertToFloat(arg)
+		if !ok {
+			return reflect.Value{}, errArgumentType
+		}
+		if reflect.Zero(t).OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %s", f, t)
+		}
+		return reflect.ValueOf(f).Convert(t), nil
+	case reflect.Bool:
+		b, ok := convertToBool(arg)
+		if !ok {
+			return reflect.Value{}, errArgumentType
+		}
+		return reflect.ValueOf(b).Convert(t), nil
+	}
+	if argVal.Type().ConvertibleTo(t) && argVal.Kind() == t.Kind() {
+		// named types, e.g. a string to a type MyString string
+		return argVal.Convert(t), nil
+	}
+	return reflect.Value{}, errArgumentType
+}
@@ -12,6 +12,7 @@ type Language struct {
 	operators       map[string]operator
 	operatorSymbols map[rune]struct{}
 	selector        func(Evaluables) Evaluable
+	limits          *Limits
 }
 
 func NewLanguage(bases ...Language) Language {
@@ -30,6 +31,9 @@ func NewLanguage(bases ...Language) Language {
 		if base.selector != nil {
 			l.selector = base.selector
 		}
+		if base.limits != nil {
+			l.limits = base.limits
+		}
 	}
 	return l
 }
@@ -43,7 +47,18 @@ func newLanguage() Language {
 }
 
 func (l Language) NewEvaluable(expression string) (Evaluable, error) {
+	return l.parse(expression, nil, false)
+}
+
+func (l Language) parse(expression string, a *analyzer, optimize bool) (Evaluable, error) {
 	p := newParser(expression, l)
+	p.analysis = a
+	p.optimize = optimize
+	if l.limits != nil {
+		var cancel context.CancelFunc
+		p.fold, _, cancel = startBudget(context.Background(), l.limits)
+		defer cancel()
+	}
 
 	eval, err := p.ParseExpression(context.Background())
 
@@ -52,8 +67,10 @@ func (l Language) NewEvaluable(expression string) (Evaluable, error) {
 	}
 
 	if err != nil {
-		pos := p.scanner.Pos()
-		return nil, fmt.Errorf("parsing error: %s - %d:%d %s", p.scanner.Position, pos.Line, pos.Column, err)
+		return nil, p.parseError(err)
+	}
+	if a == nil {
+		eval = l.sandboxed(eval)
 	}
 	return eval, nil
 }
@@ -65,14 +82,22 @@ func (l Language) Evaluate(expression string, parameter interface{}) (interface{
 	}
 	v, err := eval(context.Background(), parameter)
 	if err != nil {
-		return nil, fmt.Errorf("can not evaluate %s: %v", expression, err)
+		return nil, fmt.Errorf("can not evaluate %s: %w", expression, err)
 	}
 	return v, nil
 }
 
 func Function(name string, function interface{}) Language {
+	return typedFunction(name, function, signatureOf(function))
+}
+
+func typedFunction(name string, function interface{}, s *signature) Language {
 	l := newLanguage()
 	l.prefixes[name] = func(c context.Context, p *Parser) (eval Evaluable, err error) {
+		pos, mark := p.pos(), 0
+		if p.analysis != nil {
+			mark = p.analysis.mark()
+		}
 		args := []Evaluable{}
 		scan := p.Scan()
 		switch scan {
@@ -84,6 +109,9 @@ func Function(name string, function interface{}) Language {
 		default:
 			p.Camouflage("function call", '(')
 		}
+		if p.analysis != nil {
+			p.analysis.declare(p.analysis.call(pos, name, s, p.analysis.since(mark)))
+		}
 		return p.callFunc(toFunc(function), args...), nil
 	}
 	return l
@@ -92,6 +120,9 @@ func Function(name string, function interface{}) Language {
 func Constant(name string, value interface{}) Language {
 	l := newLanguage()
 	l.prefixes[l.makePrefixKey(name)] = func(c context.Context, p *Parser) (eval Evaluable, err error) {
+		if p.analysis != nil {
+			p.analysis.declare(TypeOf(value))
+		}
 		return p.Const(value), nil
 	}
 	return l
@@ -121,19 +152,23 @@ func PrefixMetaPrefix(r rune, ext func(context.Context, *Parser) (call string, a
 func PrefixOperator(name string, e Evaluable) Language {
 	l := newLanguage()
 	l.prefixes[l.makePrefixKey(name)] = func(c context.Context, p *Parser) (Evaluable, error) {
+		pos := p.pos()
 		eval, err := p.ParseNextExpression(c)
 		if err != nil {
 			return nil, err
 		}
-		prefix := func(c context.Context, v interface{}) (interface{}, error) {
+		if p.analysis != nil {
+			p.analysis.declare(p.analysis.prefix(pos, name, e, p.analysis.pop()))
+		}
+		prefix := p.limit(func(c context.Context, v interface{}) (interface{}, error) {
 			a, err := eval(c, v)
 			if err != nil {
 				return nil, err
 			}
 			return e(c, a)
-		}
+		})
 		if eval.IsConst() {
-			v, err := prefix(context.Background(), nil)
+			v, err := prefix(p.foldContext(), nil)
 			if err != nil {
 				return nil, err
 			}
@@ -170,6 +205,22 @@ func InfixNumberOperator(name string, f func(a, b float64) (interface{}, error))
 	return newLanguageOperator(name, &infix{number: f})
 }
 
+// infixFloatOperator is an InfixNumberOperator which Compile evaluates without boxing.
+func infixFloatOperator(name string, f func(a, b float64) float64) Language {
+	return newLanguageOperator(name, &infix{
+		number: func(a, b float64) (interface{}, error) { return f(a, b), nil },
+		float:  f,
+	})
+}
+
+// infixCompareOperator is an InfixNumberOperator which Compile evaluates without boxing.
+func infixCompareOperator(name string, f func(a, b float64) bool) Language {
+	return newLanguageOperator(name, &infix{
+		number:  func(a, b float64) (interface{}, error) { return f(a, b), nil },
+		compare: f,
+	})
+}
+
 func InfixBoolOperator(name string, f func(a, b bool) (interface{}, error)) Language {
 	return newLanguageOperator(name, &infix{boolean: f})
 }
@@ -11,26 +11,43 @@ type stage struct {
 	Evaluable
 	infixBuilder
 	operatorPrecedence
+	// static type of Evaluable and of the infix, only set by Analyze
+	typ       Type
+	infixType func(x, y Type) Type
+	// numeric form of Evaluable and infix operator, only set by Compile
+	num   numEvaluable
+	infix *infix
 }
 
 type stageStack []stage
 
-func (s *stageStack) push(b stage) error {
+// push folds the operations of constant operands, evaluated on c which
+// carries the parsing budget in a sandbox
+func (s *stageStack) push(c context.Context, b stage) error {
 	for len(*s) > 0 && s.peek().operatorPrecedence >= b.operatorPrecedence {
 		a := s.pop()
+		if a.infixType != nil {
+			b.typ = a.infixType(a.typ, b.typ)
+		}
 		eval, err := a.infixBuilder(a.Evaluable, b.Evaluable)
 		if err != nil {
 			return err
 		}
-		if a.IsConst() && b.IsConst() {
-			v, err := eval(nil, nil)
+		var num numEvaluable
+		if a.infix != nil {
+			eval, num = a.infix.compile(a, b, eval)
+		}
+		if a.IsConst() && b.IsConst() || eval.IsConst() {
+			v, err := eval(c, nil)
 			if err != nil {
 				return err
 			}
 			b.Evaluable = constant(v)
+			b.num = constNum(v)
 			continue
 		}
 		b.Evaluable = eval
+		b.num = num
 	}
 	*s = append(*s, b)
 	return nil
@@ -250,6 +267,9 @@ type infix struct {
 	arbitrary    func(a, b interface{}) (interface{}, error)
 	shortCircuit func(a interface{}) (interface{}, bool)
 	builder      infixBuilder
+	// unboxed forms of number, used by Compile
+	float   func(a, b float64) float64
+	compare func(a, b float64) bool
 }
 
 func (op infix) merge(op2 operator) operator {
@@ -257,6 +277,8 @@ func (op infix) merge(op2 operator) operator {
 	case *infix:
 		if op2.number != nil {
 			op.number = op2.number
+			op.float = op2.float
+			op.compare = op2.compare
 		}
 		if op2.boolean != nil {
 			op.boolean = op2.boolean
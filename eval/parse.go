@@ -9,32 +9,63 @@ import (
 )
 
 func (p *Parser) ParseExpression(c context.Context) (eval Evaluable, err error) {
+	eval, _, err = p.parseExpression(c)
+	return eval, err
+}
+
+func (p *Parser) parseExpression(c context.Context) (Evaluable, numEvaluable, error) {
 	stack := stageStack{}
 	for {
-		eval, err = p.ParseNextExpression(c)
+		eval, num, err := p.parseNext(c)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		if stage, err := p.parseOperator(c, &stack, eval); err != nil {
-			return nil, err
-		} else if err = stack.push(stage); err != nil {
-			return nil, err
+		if stage, err := p.parseOperator(c, &stack, eval, num); err != nil {
+			return nil, nil, err
+		} else if err = stack.push(p.foldContext(), stage); err != nil {
+			return nil, nil, err
 		}
 
 		if stack.peek().infixBuilder == nil {
-			return stack.pop().Evaluable, nil
+			last := stack.pop()
+			if p.analysis != nil {
+				p.analysis.push(last.typ)
+			}
+			return last.Evaluable, last.num, nil
 		}
 	}
 }
 
 func (p *Parser) ParseNextExpression(c context.Context) (eval Evaluable, err error) {
+	eval, _, err = p.parseNext(c)
+	return eval, err
+}
+
+func (p *Parser) parseNext(c context.Context) (eval Evaluable, num numEvaluable, err error) {
 	scan := p.Scan()
 	ex, ok := p.prefixes[scan]
 	if !ok {
-		return nil, p.Expected("extensions")
+		return nil, nil, p.Expected("extensions")
+	}
+	mark := 0
+	if p.analysis != nil {
+		mark = p.analysis.mark()
+	}
+	p.depth++
+	if p.limits != nil && p.limits.MaxDepth > 0 && p.depth > p.limits.MaxDepth {
+		return nil, nil, &LimitError{Limit: "depth", Max: int64(p.limits.MaxDepth)}
 	}
-	return ex(c, p)
+	eval, err = ex(c, p)
+	if p.numDepth == p.depth {
+		num = p.num
+	}
+	p.num, p.numDepth = nil, 0
+	p.depth--
+	if p.analysis != nil {
+		p.analysis.settle(mark)
+	}
+	return eval, num, err
 }
 
 func parseString(c context.Context, p *Parser) (Evaluable, error) {
@@ -42,6 +73,9 @@ func parseString(c context.Context, p *Parser) (Evaluable, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not parse string: %s", err)
 	}
+	if p.analysis != nil {
+		p.analysis.declare(String)
+	}
 	return p.Const(s), nil
 }
 
@@ -50,26 +84,36 @@ func parseNumber(c context.Context, p *Parser) (Evaluable, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p.analysis != nil {
+		p.analysis.declare(Number)
+	}
+	p.declareNum(constNum(n))
 	return p.Const(n), nil
 }
 
 func parseParentheses(c context.Context, p *Parser) (Evaluable, error) {
-	eval, err := p.ParseExpression(c)
+	eval, num, err := p.parseExpression(c)
 	if err != nil {
 		return nil, err
 	}
 	switch p.Scan() {
 	case ')':
+		p.declareNum(num)
 		return eval, nil
 	default:
 		return nil, p.Expected("parentheses", ')')
 	}
 }
 
-func (p *Parser) parseOperator(c context.Context, stack *stageStack, eval Evaluable) (st stage, err error) {
+func (p *Parser) parseOperator(c context.Context, stack *stageStack, eval Evaluable, num numEvaluable) (st stage, err error) {
+	var typ Type
+	if p.analysis != nil {
+		typ = p.analysis.pop()
+	}
 	for {
 		scan := p.Scan()
 		op := p.TokenText()
+		pos := p.pos()
 		mustOp := false
 		if p.isSymbolOperation(scan) {
 			scan = p.Peek()
@@ -81,51 +125,85 @@ func (p *Parser) parseOperator(c context.Context, stack *stageStack, eval Evalua
 			}
 		} else if scan != scanner.Ident {
 			p.Camouflage("operator")
-			return stage{Evaluable: eval}, nil
+			return stage{Evaluable: eval, typ: typ, num: num}, nil
 		}
 		operator, _ := p.operators[op]
 		switch operator := operator.(type) {
 		case *infix:
 			return stage{
 				Evaluable:          eval,
-				infixBuilder:       operator.builder,
+				infixBuilder:       p.limitInfix(operator.builder),
 				operatorPrecedence: operator.operatorPrecedence,
+				typ:                typ,
+				infixType:          p.infixType(pos, op, operator),
+				num:                num,
+				infix:              p.optimized(operator),
 			}, nil
 		case directInfix:
 			return stage{
 				Evaluable:          eval,
-				infixBuilder:       operator.infixBuilder,
+				infixBuilder:       p.limitInfix(operator.infixBuilder),
 				operatorPrecedence: operator.operatorPrecedence,
+				typ:                typ,
+				infixType:          p.infixType(pos, op, operator),
 			}, nil
 		case postfix:
-			if err = stack.push(stage{
+			if err = stack.push(p.foldContext(), stage{
 				operatorPrecedence: operator.operatorPrecedence,
 				Evaluable:          eval,
+				typ:                typ,
 			}); err != nil {
 				return stage{}, err
 			}
-			eval, err = operator.f(c, p, stack.pop().Evaluable, operator.operatorPrecedence)
+			last := stack.pop()
+			num = nil
+			if p.analysis == nil {
+				eval, err = operator.f(c, p, last.Evaluable, operator.operatorPrecedence)
+				if err != nil {
+					return
+				}
+				eval = p.limit(eval)
+				continue
+			}
+			// the operand is pushed for postfixes which declare their type from it
+			mark := p.analysis.mark()
+			p.analysis.push(last.typ)
+			eval, err = operator.f(c, p, last.Evaluable, operator.operatorPrecedence)
 			if err != nil {
 				return
 			}
+			eval = p.limit(eval)
+			p.analysis.settle(mark)
+			typ = p.analysis.pop()
 			continue
 		}
 
 		if !mustOp {
 			p.Camouflage("operator")
-			return stage{Evaluable: eval}, nil
+			return stage{Evaluable: eval, typ: typ, num: num}, nil
 		}
-		return stage{}, fmt.Errorf("unknown operator %s", op)
+		return stage{}, unknownOperator{op, pos}
+	}
+}
+
+func (p *Parser) infixType(pos Position, name string, op operator) func(x, y Type) Type {
+	if p.analysis == nil {
+		return nil
+	}
+	return func(x, y Type) Type {
+		return p.analysis.infix(pos, name, op, x, y)
 	}
 }
 
 func parseIdent(c context.Context, p *Parser) (call string, alternative func() (Evaluable, error), err error) {
 	token := p.TokenText()
+	pos := p.pos()
 	return token,
 		func() (Evaluable, error) {
 			fullname := token
 
 			keys := []Evaluable{p.Const(token)}
+			path := []string{token}
 			for {
 				scan := p.Scan()
 				switch scan {
@@ -135,6 +213,7 @@ func parseIdent(c context.Context, p *Parser) (call string, alternative func() (
 					case scanner.Ident:
 						token = p.TokenText()
 						keys = append(keys, p.Const(token))
+						path = append(path, token)
 					default:
 						return nil, p.Expected("field", scanner.Ident)
 					}
@@ -143,7 +222,16 @@ func parseIdent(c context.Context, p *Parser) (call string, alternative func() (
 					if err != nil {
 						return nil, err
 					}
-					return p.callEvaluable(fullname, p.Var(keys...), args...), nil
+					// an unknown function is a variable, the closest functions are suggested when it isn't one
+					var suggestions []string
+					if len(keys) == 1 {
+						suggestions = suggest(fullname, p.names())
+					}
+					if p.analysis != nil {
+						p.analysis.callVariable(pos, path, suggestions)
+						p.analysis.declare(Any)
+					}
+					return p.callEvaluable(fullname, suggestions, p.Var(keys...), args...), nil
 				case '[':
 					key, err := p.ParseExpression(c)
 					if err != nil {
@@ -152,18 +240,38 @@ func parseIdent(c context.Context, p *Parser) (call string, alternative func() (
 					switch p.Scan() {
 					case ']':
 						keys = append(keys, key)
+						path = append(path, keyPath(key))
 					default:
 						return nil, p.Expected("array key", ']')
 					}
 				default:
 					p.Camouflage("variable", '.', '(', '[')
-					return p.Var(keys...), nil
+					if p.analysis != nil {
+						p.analysis.declare(p.analysis.variable(pos, path))
+					}
+					if p.optimize && p.Language.selector == nil {
+						if eval, num := compileVariable(keys); eval != nil {
+							p.declareNum(num)
+							return p.suggestNames(path, eval), nil
+						}
+					}
+					return p.suggestNames(path, p.Var(keys...)), nil
 				}
 			}
 		}, nil
 
 }
 
+// keyPath is the path segment of a[key], * if key isn't constant
+func keyPath(key Evaluable) string {
+	if key.IsConst() {
+		if s, err := key.EvalString(context.Background(), nil); err == nil {
+			return s
+		}
+	}
+	return "*"
+}
+
 func (p *Parser) parseArguments(c context.Context) (args []Evaluable, err error) {
 	if p.Scan() == ')' {
 		return
@@ -199,6 +307,10 @@ func inArray(a, b interface{}) (interface{}, error) {
 }
 
 func parseIf(c context.Context, p *Parser, e Evaluable) (Evaluable, error) {
+	mark := 0
+	if p.analysis != nil {
+		mark = p.analysis.mark()
+	}
 	a, err := p.ParseExpression(c)
 	if err != nil {
 		return nil, err
@@ -214,6 +326,23 @@ func parseIf(c context.Context, p *Parser, e Evaluable) (Evaluable, error) {
 	default:
 		return nil, p.Expected("<> ? <> : <>", ':', scanner.EOF)
 	}
+	if p.analysis != nil {
+		// without else the type is the one of the then branch, or nil
+		t := p.analysis.since(mark)
+		if len(t) == 1 || len(t) == 2 && t[0] == t[1] {
+			p.analysis.declare(t[0])
+		} else {
+			p.analysis.declare(Any)
+		}
+	}
+	if p.optimize && e.IsConst() {
+		if x, err := e(c, nil); err == nil {
+			if x == false || x == nil {
+				return b, nil
+			}
+			return a, nil
+		}
+	}
 	return func(c context.Context, v interface{}) (interface{}, error) {
 		x, err := e(c, v)
 		if err != nil {
@@ -239,6 +368,14 @@ func parseJSONArray(c context.Context, p *Parser) (Evaluable, error) {
 			evals = append(evals, eval)
 		case ',':
 		case ']':
+			if p.analysis != nil {
+				p.analysis.declare(Array)
+			}
+			if p.optimize {
+				if vs, ok := constValues(evals); ok {
+					return p.Const(vs), nil
+				}
+			}
 			return func(c context.Context, v interface{}) (interface{}, error) {
 				vs := make([]interface{}, len(evals))
 				for i, e := range evals {
@@ -281,6 +418,9 @@ func parseJSONObject(c context.Context, p *Parser) (Evaluable, error) {
 			evals = append(evals, kv{key, value})
 		case ',':
 		case '}':
+			if p.analysis != nil {
+				p.analysis.declare(Object)
+			}
 			return func(c context.Context, v interface{}) (interface{}, error) {
 				vs := map[string]interface{}{}
 				for _, e := range evals {
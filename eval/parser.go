@@ -2,6 +2,7 @@ package eval
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"text/scanner"
@@ -9,10 +10,19 @@ import (
 )
 
 type Parser struct {
-	scanner scanner.Scanner
+	scanner    scanner.Scanner
+	expression string
 	Language
 	lastScan   rune
 	camouflage error
+	analysis   *analyzer
+	// set by Compile, num is the numeric form declared by the prefix at depth numDepth
+	optimize bool
+	depth    int
+	num      numEvaluable
+	numDepth int
+	// context of the constant operations folded while parsing, set in a sandbox
+	fold context.Context
 }
 
 func newParser(expression string, l Language) *Parser {
@@ -21,7 +31,7 @@ func newParser(expression string, l Language) *Parser {
 	sc.Error = func(*scanner.Scanner, string) { return }
 	sc.IsIdentRune = func(r rune, pos int) bool { return unicode.IsLetter(r) || r == '_' || (pos > 0 && unicode.IsDigit(r)) }
 	sc.Filename = expression + "\t"
-	return &Parser{scanner: sc, Language: l}
+	return &Parser{scanner: sc, expression: expression, Language: l}
 }
 
 func (p *Parser) Scan() rune {
@@ -63,6 +73,11 @@ func (p *Parser) Next() rune {
 	return p.scanner.Next()
 }
 
+// pos is the position of the last scanned token
+func (p *Parser) pos() Position {
+	return Position{Offset: p.scanner.Position.Offset, Line: p.scanner.Position.Line, Column: p.scanner.Position.Column}
+}
+
 func (p *Parser) TokenText() string {
 	return p.scanner.TokenText()
 }
@@ -0,0 +1,212 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bound the work of expressions written by untrusted users,
+// zero values are unlimited. The operations on constants folded while
+// parsing count as an evaluation of their own, bounded by the same limits.
+type Limits struct {
+	// Operators and function calls of an evaluation, including the ones
+	// evaluated for each element by map, filter, any and all
+	MaxOperations int
+	// Nesting of parentheses, operands and arguments, checked when parsing
+	MaxDepth int
+	// Length of the strings, arrays and objects produced by operators and functions
+	MaxSize int
+	// Wall-clock time of an evaluation, the deadline of the context still applies.
+	// Functions taking a context.Context receive it.
+	Timeout time.Duration
+}
+
+// ErrLimitExceeded is matched by the LimitError of an expression exceeding its Limits:
+// errors.Is(err, ErrLimitExceeded).
+var ErrLimitExceeded = errors.New("eval: limit exceeded")
+
+// LimitError is returned by parsing and evaluation when a limit is exceeded.
+type LimitError struct {
+	// operations, depth, size or timeout
+	Limit string
+	// The exceeded limit, nanoseconds for timeout
+	Max int64
+}
+
+func (err *LimitError) Error() string {
+	switch err.Limit {
+	case "operations":
+		return fmt.Sprintf("%s: more than %d operations", ErrLimitExceeded, err.Max)
+	case "depth":
+		return fmt.Sprintf("%s: nested deeper than %d", ErrLimitExceeded, err.Max)
+	case "size":
+		return fmt.Sprintf("%s: value larger than %d", ErrLimitExceeded, err.Max)
+	case "timeout":
+		return fmt.Sprintf("%s: evaluation longer than %s", ErrLimitExceeded, time.Duration(err.Max))
+	}
+	return ErrLimitExceeded.Error()
+}
+
+func (err *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// Sandbox limits the expressions of a language: Full(Sandbox(Limits{...})).
+// Compile doesn't evaluate numbers unboxed in a sandbox, every operation is counted.
+func Sandbox(limits Limits) Language {
+	l := newLanguage()
+	l.limits = &limits
+	return l
+}
+
+type budgetKey struct{}
+
+// budget of an evaluation, shared by its lambdas through the context
+type budget struct {
+	limits     *Limits
+	operations int64
+	deadline   time.Time
+}
+
+func budgetOf(c context.Context) *budget {
+	if c == nil {
+		return nil
+	}
+	b, _ := c.Value(budgetKey{}).(*budget)
+	return b
+}
+
+// step counts an operation and checks the deadline
+func (b *budget) step(c context.Context) error {
+	n := atomic.AddInt64(&b.operations, 1)
+	if max := b.limits.MaxOperations; max > 0 && n > int64(max) {
+		return &LimitError{Limit: "operations", Max: int64(max)}
+	}
+	if err := c.Err(); err != nil {
+		return b.timeout(err)
+	}
+	return nil
+}
+
+// timeout returns a LimitError for the deadline of the Timeout, which functions
+// taking a context return as context.DeadlineExceeded
+func (b *budget) timeout(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) && !b.deadline.IsZero() && !time.Now().Before(b.deadline) {
+		return &LimitError{Limit: "timeout", Max: int64(b.limits.Timeout)}
+	}
+	return err
+}
+
+func (b *budget) checkSize(v interface{}) error {
+	max := b.limits.MaxSize
+	if max <= 0 {
+		return nil
+	}
+	n := 0
+	switch v := v.(type) {
+	case nil, bool, float64:
+		return nil
+	case string:
+		n = len(v)
+	case []interface{}:
+		n = len(v)
+	case map[string]interface{}:
+		n = len(v)
+	default:
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			n = rv.Len()
+		}
+	}
+	if n > max {
+		return &LimitError{Limit: "size", Max: int64(max)}
+	}
+	return nil
+}
+
+// limit counts the evaluations of an operator or a function call and checks
+// the size of its results. Evaluables are left as they are outside of a sandbox.
+func (p *Parser) limit(e Evaluable) Evaluable {
+	if p.limits == nil || e.IsConst() {
+		return e
+	}
+	return func(c context.Context, v interface{}) (interface{}, error) {
+		b := budgetOf(c)
+		if b == nil {
+			return e(c, v)
+		}
+		if err := b.step(c); err != nil {
+			return nil, err
+		}
+		r, err := e(c, v)
+		if err != nil {
+			return nil, err
+		}
+		if err = b.checkSize(r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+func (p *Parser) limitInfix(builder infixBuilder) infixBuilder {
+	if p.limits == nil {
+		return builder
+	}
+	return func(a, b Evaluable) (Evaluable, error) {
+		eval, err := builder(a, b)
+		if err != nil {
+			return nil, err
+		}
+		return p.limit(eval), nil
+	}
+}
+
+// startBudget starts a budget of the limits and its timeout on c
+func startBudget(c context.Context, limits *Limits) (context.Context, *budget, context.CancelFunc) {
+	b := &budget{limits: limits}
+	c = context.WithValue(c, budgetKey{}, b)
+	if limits.Timeout <= 0 {
+		return c, b, func() {}
+	}
+	b.deadline = time.Now().Add(limits.Timeout)
+	c, cancel := context.WithDeadline(c, b.deadline)
+	return c, b, cancel
+}
+
+// foldContext is the context of the constant operations folded while parsing.
+// In a sandbox they share the budget of the parsing, so that an expression
+// can't escape its limits by building a huge constant.
+func (p *Parser) foldContext() context.Context {
+	if p.fold == nil {
+		return context.Background()
+	}
+	return p.fold
+}
+
+// sandboxed starts a budget and the timeout for each evaluation
+func (l Language) sandboxed(e Evaluable) Evaluable {
+	if l.limits == nil || e.IsConst() {
+		return e
+	}
+	limits := l.limits
+	return func(c context.Context, v interface{}) (interface{}, error) {
+		if c == nil {
+			c = context.Background()
+		}
+		c, b, cancel := startBudget(c, limits)
+		defer cancel()
+		r, err := e(c, v)
+		if err == nil {
+			err = b.checkSize(r)
+		}
+		if err != nil {
+			return nil, b.timeout(err)
+		}
+		return r, nil
+	}
+}
@@ -0,0 +1,642 @@
+package eval
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/scanner"
+	"time"
+	"unicode/utf8"
+)
+
+var stdlib = NewLanguage(
+	stdFunction("len", newSignature(Number, 1, 1), stdLen),
+	stdFunction("upper", newSignature(String, 1, 1, String), func(args []interface{}) (interface{}, error) {
+		s, err := stringArgument("upper", args, 0)
+		return strings.ToUpper(s), err
+	}),
+	stdFunction("lower", newSignature(String, 1, 1, String), func(args []interface{}) (interface{}, error) {
+		s, err := stringArgument("lower", args, 0)
+		return strings.ToLower(s), err
+	}),
+	stdFunction("trim", newSignature(String, 1, 2, String), stdTrim),
+	stdFunction("contains", newSignature(Bool, 2, 2), stdContains),
+	stdFunction("startsWith", newSignature(Bool, 2, 2, String), func(args []interface{}) (interface{}, error) {
+		s, prefix, err := stringArguments("startsWith", args)
+		return strings.HasPrefix(s, prefix), err
+	}),
+	stdFunction("endsWith", newSignature(Bool, 2, 2, String), func(args []interface{}) (interface{}, error) {
+		s, suffix, err := stringArguments("endsWith", args)
+		return strings.HasSuffix(s, suffix), err
+	}),
+	stdFunction("substr", newSignature(String, 2, 3, String, Number), stdSubstr),
+	stdFunction("regexMatch", newSignature(Bool, 2, 2, String), stdRegexMatch),
+
+	stdFunction("abs", newSignature(Number, 1, 1, Number), numberFunction("abs", math.Abs)),
+	stdFunction("floor", newSignature(Number, 1, 1, Number), numberFunction("floor", math.Floor)),
+	stdFunction("ceil", newSignature(Number, 1, 1, Number), numberFunction("ceil", math.Ceil)),
+	stdFunction("round", newSignature(Number, 1, 2, Number), stdRound),
+	stdFunction("min", newSignature(Number, 1, -1), func(args []interface{}) (interface{}, error) {
+		return stdExtremum("min", args, func(a, b float64) bool { return a < b })
+	}),
+	stdFunction("max", newSignature(Number, 1, -1), func(args []interface{}) (interface{}, error) {
+		return stdExtremum("max", args, func(a, b float64) bool { return a > b })
+	}),
+
+	stdFunction("now", newSignature(Time, 0, 0), func(args []interface{}) (interface{}, error) {
+		return time.Now(), nil
+	}),
+	stdFunction("date", newSignature(Time, 1, 1), func(args []interface{}) (interface{}, error) {
+		return timeArgument("date", args, 0)
+	}),
+	stdFunction("dateAdd", newSignature(Time, 3, 3, Any, Number, String), stdDateAdd),
+	stdFunction("format", newSignature(String, 2, 2, Any, String), stdFormat),
+
+	stdFunction("keys", newSignature(Array, 1, 1, Object), func(args []interface{}) (interface{}, error) {
+		keys, _, err := mapArgument("keys", args[0])
+		return keys, err
+	}),
+	stdFunction("values", newSignature(Array, 1, 1, Object), func(args []interface{}) (interface{}, error) {
+		_, values, err := mapArgument("values", args[0])
+		return values, err
+	}),
+
+	stdFunction("coalesce", newSignature(Any, 1, -1), func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}),
+	stdFunction("ifnull", newSignature(Any, 2, 2), func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return args[1], nil
+		}
+		return args[0], nil
+	}),
+
+	lambdaFunction("map", Array, false, func(items []interface{}, body func(interface{}) (interface{}, error)) (interface{}, error) {
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := body(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	}),
+	lambdaFunction("filter", Array, true, func(items []interface{}, body func(interface{}) (interface{}, error)) (interface{}, error) {
+		result := []interface{}{}
+		for _, item := range items {
+			keep, err := lambdaBool("filter", body, item)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	}),
+	lambdaFunction("any", Bool, true, func(items []interface{}, body func(interface{}) (interface{}, error)) (interface{}, error) {
+		for _, item := range items {
+			ok, err := lambdaBool("any", body, item)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}),
+	lambdaFunction("all", Bool, true, func(items []interface{}, body func(interface{}) (interface{}, error)) (interface{}, error) {
+		for _, item := range items {
+			ok, err := lambdaBool("all", body, item)
+			if err != nil || !ok {
+				return ok, err
+			}
+		}
+		return true, nil
+	}),
+)
+
+// stdFunction checks the number of arguments against the signature, its
+// parameter types are only used by Analyze.
+func stdFunction(name string, s *signature, f func(args []interface{}) (interface{}, error)) Language {
+	min, max := s.min, s.max
+	return typedFunction(name, func(c context.Context, arguments ...interface{}) (interface{}, error) {
+		switch {
+		case min == max && len(arguments) != min:
+			return nil, fmt.Errorf("%s() expects %d arguments but got %d", name, min, len(arguments))
+		case len(arguments) < min:
+			return nil, fmt.Errorf("%s() expects at least %d arguments but got %d", name, min, len(arguments))
+		case max >= 0 && len(arguments) > max:
+			return nil, fmt.Errorf("%s() expects at most %d arguments but got %d", name, max, len(arguments))
+		}
+		return f(arguments)
+	}, s)
+}
+
+// lambdaFunction parses name(collection, x => body), body is evaluated for
+// each element of collection with x bound to the element.
+// result is the type of the function, for Analyze, and condition whether body must be a bool.
+func lambdaFunction(name string, result Type, condition bool, apply func(items []interface{}, body func(interface{}) (interface{}, error)) (interface{}, error)) Language {
+	l := newLanguage()
+	l.prefixes[name] = func(c context.Context, p *Parser) (Evaluable, error) {
+		pos := p.pos()
+		if p.Scan() != '(' {
+			return nil, p.Expected(name, '(')
+		}
+		collection, err := p.ParseExpression(c)
+		if err != nil {
+			return nil, err
+		}
+		if p.Scan() != ',' {
+			return nil, p.Expected(name, ',')
+		}
+		if p.Scan() != scanner.Ident {
+			return nil, p.Expected("lambda parameter", scanner.Ident)
+		}
+		param := p.TokenText()
+		if p.Scan() != '=' {
+			return nil, p.Expected("lambda", '=')
+		}
+		if p.Scan() != '>' {
+			return nil, p.Expected("lambda", '>')
+		}
+		if p.analysis != nil {
+			p.analysis.scopes = append(p.analysis.scopes, param)
+		}
+		body, err := p.ParseExpression(c)
+		if err != nil {
+			return nil, err
+		}
+		if p.Scan() != ')' {
+			return nil, p.Expected(name, ')')
+		}
+		if a := p.analysis; a != nil {
+			a.scopes = a.scopes[:len(a.scopes)-1]
+			bodyType, colType := a.pop(), a.pop()
+			a.call(pos, name, newSignature(result, 0, 0), nil)
+			if colType != Any && colType != Array {
+				a.errorf(pos, "%s() expects an array but got %s", name, colType)
+			}
+			if condition && bodyType != Any && bodyType != Bool {
+				a.errorf(pos, "%s() expects a bool condition but got %s", name, bodyType)
+			}
+			a.declare(result)
+		}
+		return p.limit(func(c context.Context, v interface{}) (interface{}, error) {
+			col, err := collection(c, v)
+			if err != nil {
+				return nil, err
+			}
+			items, ok := toArray(col)
+			if !ok {
+				return nil, fmt.Errorf("%s() expects an array but got %T", name, col)
+			}
+			b := budgetOf(c)
+			return apply(items, func(item interface{}) (interface{}, error) {
+				if b != nil {
+					if err := b.step(c); err != nil {
+						return nil, err
+					}
+				}
+				return body(c, lambdaScope{name: param, value: item, outer: v})
+			})
+		}), nil
+	}
+	return l
+}
+
+func lambdaBool(name string, body func(interface{}) (interface{}, error), item interface{}) (bool, error) {
+	v, err := body(item)
+	if err != nil {
+		return false, err
+	}
+	b, ok := convertToBool(v)
+	if !ok {
+		return false, fmt.Errorf("%s() expects a bool condition but got %T", name, v)
+	}
+	return b, nil
+}
+
+// lambdaScope is the parameter of a lambda body: the lambda parameter and
+// the variables of the enclosing expression.
+type lambdaScope struct {
+	name  string
+	value interface{}
+	outer interface{}
+}
+
+func (s lambdaScope) SelectEval(c context.Context, key string) (interface{}, error) {
+	if key == s.name {
+		return s.value, nil
+	}
+	return variable(Evaluables{constant(key)})(c, s.outer)
+}
+
+func toArray(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, true
+	case []interface{}:
+		return v, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+func stdLen(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return 0., nil
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	rv := reflect.ValueOf(args[0])
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return float64(rv.Len()), nil
+	}
+	return nil, fmt.Errorf("len() expects a string, array or object but got %T", args[0])
+}
+
+func stdTrim(args []interface{}) (interface{}, error) {
+	s, err := stringArgument("trim", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return strings.TrimSpace(s), nil
+	}
+	cutset, err := stringArgument("trim", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Trim(s, cutset), nil
+}
+
+// contains(s, substring) for strings, contains(array, value) for arrays
+func stdContains(args []interface{}) (interface{}, error) {
+	if s, ok := args[0].(string); ok {
+		sub, err := stringArgument("contains", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(s, sub), nil
+	}
+	items, ok := toArray(args[0])
+	if !ok {
+		return nil, fmt.Errorf("contains() expects a string or array but got %T", args[0])
+	}
+	for _, item := range items {
+		if valuesEqual(item, args[1]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// substr(s, start[, length]) counts in runes, out of range bounds are clamped.
+func stdSubstr(args []interface{}) (interface{}, error) {
+	s, err := stringArgument("substr", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, err := intArgument("substr", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+	end := len(runes)
+	if len(args) == 3 {
+		length, err := intArgument("substr", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			length = 0
+		}
+		// start+length overflows for huge lengths
+		if length < end-start {
+			end = start + length
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+// regexCacheSize bounds the compiled patterns kept by regexMatch, patterns
+// built at runtime from untrusted input would grow an unbounded cache
+const regexCacheSize = 256
+
+// regexCache keeps the most recently used compiled patterns
+var regexCache = struct {
+	sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}{
+	lru:     list.New(),
+	entries: make(map[string]*list.Element),
+}
+
+type regexCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regexCache.Lock()
+	if e, ok := regexCache.entries[pattern]; ok {
+		regexCache.lru.MoveToFront(e)
+		regexCache.Unlock()
+		return e.Value.(*regexCacheEntry).re, nil
+	}
+	regexCache.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	regexCache.Lock()
+	defer regexCache.Unlock()
+	if _, ok := regexCache.entries[pattern]; !ok {
+		regexCache.entries[pattern] = regexCache.lru.PushFront(&regexCacheEntry{pattern: pattern, re: re})
+		if regexCache.lru.Len() > regexCacheSize {
+			oldest := regexCache.lru.Back()
+			regexCache.lru.Remove(oldest)
+			delete(regexCache.entries, oldest.Value.(*regexCacheEntry).pattern)
+		}
+	}
+	return re, nil
+}
+
+func stdRegexMatch(args []interface{}) (interface{}, error) {
+	s, pattern, err := stringArguments("regexMatch", args)
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regexMatch() invalid pattern: %s", err)
+	}
+	return re.MatchString(s), nil
+}
+
+func numberFunction(name string, f func(float64) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		x, err := numberArgument(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f(x), nil
+	}
+}
+
+// round(x[, digits]) rounds half away from zero
+func stdRound(args []interface{}) (interface{}, error) {
+	x, err := numberArgument("round", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return math.Round(x), nil
+	}
+	digits, err := intArgument("round", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	// 10^digits is only a finite, non zero float64 within ±308
+	switch {
+	case digits > 308:
+		return x, nil
+	case digits < -308:
+		return 0.0, nil
+	}
+	p := math.Pow(10, float64(digits))
+	scaled := x * p
+	if math.IsInf(scaled, 0) {
+		// x has no digit to round at this precision
+		return x, nil
+	}
+	return math.Round(scaled) / p, nil
+}
+
+// min and max take numbers or a single array of numbers
+func stdExtremum(name string, args []interface{}, better func(a, b float64) bool) (interface{}, error) {
+	if len(args) == 1 {
+		if items, ok := toArray(args[0]); ok {
+			args = items
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s() of an empty array", name)
+	}
+	result, err := numberArgument(name, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(args); i++ {
+		x, err := numberArgument(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		if better(x, result) {
+			result = x
+		}
+	}
+	return result, nil
+}
+
+// dateAdd(t, amount, unit) with unit year, month, week, day, hour, minute or second
+func stdDateAdd(args []interface{}) (interface{}, error) {
+	t, err := timeArgument("dateAdd", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := numberArgument("dateAdd", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := stringArgument("dateAdd", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "year":
+		return t.AddDate(int(amount), 0, 0), nil
+	case "month":
+		return t.AddDate(0, int(amount), 0), nil
+	case "week":
+		return t.AddDate(0, 0, 7*int(amount)), nil
+	case "day":
+		return t.AddDate(0, 0, int(amount)), nil
+	case "hour":
+		return t.Add(time.Duration(amount * float64(time.Hour))), nil
+	case "minute":
+		return t.Add(time.Duration(amount * float64(time.Minute))), nil
+	case "second":
+		return t.Add(time.Duration(amount * float64(time.Second))), nil
+	}
+	return nil, fmt.Errorf("dateAdd() unknown unit %s", unit)
+}
+
+var layoutReplacer = strings.NewReplacer(
+	"yyyy", "2006", "yy", "06",
+	"MM", "01", "dd", "02",
+	"HH", "15", "hh", "03",
+	"mm", "04", "ss", "05",
+	"SSS", "000",
+)
+
+// format(t, layout) accepts Go layouts and yyyy-MM-dd HH:mm:ss style layouts
+func stdFormat(args []interface{}) (interface{}, error) {
+	t, err := timeArgument("format", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := stringArgument("format", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(layout, "2006") {
+		layout = layoutReplacer.Replace(layout)
+	}
+	return t.Format(layout), nil
+}
+
+// mapArgument returns the keys of an object in sorted order and the values in the same order.
+func mapArgument(name string, arg interface{}) ([]interface{}, []interface{}, error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		names := make([]string, 0, len(m))
+		for k := range m {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		keys := make([]interface{}, len(names))
+		values := make([]interface{}, len(names))
+		for i, k := range names {
+			keys[i] = k
+			values[i] = m[k]
+		}
+		return keys, values, nil
+	}
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() != reflect.Map {
+		return nil, nil, fmt.Errorf("%s() expects an object but got %T", name, arg)
+	}
+	mapKeys := rv.MapKeys()
+	sort.Slice(mapKeys, func(i, j int) bool {
+		return fmt.Sprint(mapKeys[i].Interface()) < fmt.Sprint(mapKeys[j].Interface())
+	})
+	keys := make([]interface{}, len(mapKeys))
+	values := make([]interface{}, len(mapKeys))
+	for i, k := range mapKeys {
+		keys[i] = k.Interface()
+		values[i] = rv.MapIndex(k).Interface()
+	}
+	return keys, values, nil
+}
+
+// numbers of different types are equal if they have the same value
+func valuesEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if !isNumber(a) || !isNumber(b) {
+		return false
+	}
+	x, _ := convertToFloat(a)
+	y, _ := convertToFloat(b)
+	return x == y
+}
+
+func isNumber(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func stringArgument(name string, args []interface{}, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("%s() expects a string as argument %d but got %T", name, i+1, args[i])
+	}
+	return s, nil
+}
+
+func stringArguments(name string, args []interface{}) (string, string, error) {
+	a, err := stringArgument(name, args, 0)
+	if err != nil {
+		return "", "", err
+	}
+	b, err := stringArgument(name, args, 1)
+	return a, b, err
+}
+
+func numberArgument(name string, args []interface{}, i int) (float64, error) {
+	f, ok := convertToFloat(args[i])
+	if !ok {
+		return 0, fmt.Errorf("%s() expects a number as argument %d but got %T", name, i+1, args[i])
+	}
+	return f, nil
+}
+
+func intArgument(name string, args []interface{}, i int) (int, error) {
+	f, err := numberArgument(name, args, i)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s() expects an integer as argument %d but got %v", name, i+1, f)
+	}
+	// int(f) is undefined out of the range of int
+	if f < math.MinInt || f >= -math.MinInt {
+		return 0, fmt.Errorf("%s() argument %d overflows int: %v", name, i+1, f)
+	}
+	return int(f), nil
+}
+
+// times are time.Time or strings in one of the formats of date()
+func timeArgument(name string, args []interface{}, i int) (time.Time, error) {
+	switch t := args[i].(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t != nil {
+			return *t, nil
+		}
+	case string:
+		return parseDate(t)
+	}
+	return time.Time{}, fmt.Errorf("%s() expects a date as argument %d but got %T", name, i+1, args[i])
+}
@@ -0,0 +1,66 @@
+package eval
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSubstr(t *testing.T) {
+	parameter := map[string]interface{}{"s": strings.Repeat("a", 3000), "t": "héllo"}
+	for _, lang := range []Language{Full(Stdlib()), Full(Stdlib(), Sandbox(Limits{MaxOperations: 1000, MaxSize: 10000}))} {
+		for _, c := range []struct {
+			expression string
+			want       interface{}
+		}{
+			{`substr(t, 1, 3)`, "éll"},
+			{`substr(t, 1)`, "éllo"},
+			{`substr(t, -1, 2)`, "hé"},
+			{`substr(t, 4, 100)`, "o"},
+			{`substr(t, 9, 1)`, ""},
+			{`substr(t, 1, -1)`, ""},
+			// start+length overflows int
+			{`substr(s, 2000, 9223372036854774784)`, strings.Repeat("a", 1000)},
+		} {
+			got, err := lang.Evaluate(c.expression, parameter)
+			if err != nil || got != c.want {
+				t.Errorf("%s = %.20v, %v, want %.20v", c.expression, got, err, c.want)
+			}
+		}
+
+		for _, expression := range []string{
+			`substr(t, 1, 1e300)`,
+			`substr(t, 1, 9223372036854775808)`,
+			`substr(t, -1e19)`,
+			`substr(t, 1, 1/0)`,
+			`substr(t, 0.5)`,
+		} {
+			if got, err := lang.Evaluate(expression, parameter); err == nil {
+				t.Errorf("%s = %v, want an error", expression, got)
+			}
+		}
+	}
+}
+
+func TestRoundDigits(t *testing.T) {
+	e, err := Full(Stdlib()).NewEvaluable(`round(x, d)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		x, d, want float64
+	}{
+		{1.2345, 2, 1.23},
+		{1234.5, -2, 1200},
+		{1.5, 1000, 1.5},
+		{1.5, -1000, 0},
+	} {
+		got, err := e(context.Background(), map[string]interface{}{"x": c.x, "d": c.d})
+		if err != nil || got != c.want {
+			t.Errorf("round(%v, %v) = %v, %v, want %v", c.x, c.d, got, err, c.want)
+		}
+	}
+	if got, err := e(context.Background(), map[string]interface{}{"x": 1.5, "d": 1e20}); err == nil {
+		t.Errorf("round(1.5, 1e20) = %v, want an error", got)
+	}
+}
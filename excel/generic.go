@@ -0,0 +1,143 @@
+package excel
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+// schemaCache keeps the parsed schema of every struct type read by the typed
+// readers, so the tags are only parsed once per type for the whole process.
+var schemaCache sync.Map // map[reflect.Type]*schema
+
+func loadSchema(t reflect.Type) *schema {
+	if s, ok := schemaCache.Load(t); ok {
+		return s.(*schema)
+	}
+	s, _ := schemaCache.LoadOrStore(t, newSchema(t))
+	return s.(*schema)
+}
+
+// SheetIterator read a sheet row by row into values of T.
+// T can be a struct, a pointer to struct, a map of string key or a slice.
+type SheetIterator[T any] struct {
+	rd     *read
+	schema *schema
+	isPtr  bool
+	cur    T
+	err    error
+}
+
+// NewSheetIterator make an iterator of the sheet selected by cfg.
+// If cfg is nil or cfg.Sheet is nil, the sheet name is inferred from T
+// the same way as NewReader does.
+func NewSheetIterator[T any](conn Connecter, cfg *Config) (*SheetIterator[T], error) {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Sheet == nil {
+		c.Sheet = []T(nil)
+	}
+
+	reader, err := conn.NewReaderByConfig(&c)
+	if err != nil {
+		if reader != nil {
+			reader.Close()
+		}
+		return nil, err
+	}
+	rd, ok := reader.(*read)
+	if !ok {
+		reader.Close()
+		return nil, ErrInvalidConatiner
+	}
+
+	it := &SheetIterator[T]{rd: rd}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+		it.isPtr = true
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Struct {
+		it.schema = loadSchema(typ)
+	}
+	return it, nil
+}
+
+// Next read the next row, return false when there is no more row or an error occurred.
+func (it *SheetIterator[T]) Next() bool {
+	if it.err != nil || it.rd == nil || it.rd.title == nil {
+		return false
+	}
+	if !it.rd.Next() {
+		return false
+	}
+
+	var v T
+	var err error
+	if it.schema == nil {
+		for err = ErrEmptyRow; err == ErrEmptyRow; {
+			err = it.rd.Read(&v)
+		}
+	} else {
+		var elem reflect.Value
+		if it.isPtr {
+			ptr := reflect.New(it.schema.Type)
+			reflect.ValueOf(&v).Elem().Set(ptr)
+			elem = ptr.Elem()
+		} else {
+			elem = reflect.ValueOf(&v).Elem()
+		}
+		for err = ErrEmptyRow; err == ErrEmptyRow; {
+			err = it.rd.readToValue(it.schema, elem)
+		}
+	}
+	if err != nil {
+		if err != io.EOF {
+			// EOF is normal.
+			it.err = err
+		}
+		return false
+	}
+	it.cur = v
+	return true
+}
+
+// Value return the row read by the last call to Next.
+func (it *SheetIterator[T]) Value() T {
+	return it.cur
+}
+
+// Err return the error stopped the iteration, nil if reach the end of sheet.
+func (it *SheetIterator[T]) Err() error {
+	return it.err
+}
+
+// Close the underlying reader.
+func (it *SheetIterator[T]) Close() error {
+	if it.rd == nil {
+		return nil
+	}
+	err := it.rd.Close()
+	it.rd = nil
+	return err
+}
+
+// ReadSheet read all rows of the sheet selected by cfg into a slice of T.
+func ReadSheet[T any](conn Connecter, cfg *Config) ([]T, error) {
+	it, err := NewSheetIterator[T](conn, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var rows []T
+	for it.Next() {
+		rows = append(rows, it.Value())
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -97,7 +97,7 @@ func (rd *read) ReadAll(container interface{}) error {
 	var err error
 	switch elemTyp.Kind() {
 	case reflect.Struct:
-		elemSchema := newSchema(elemTyp)
+		elemSchema := loadSchema(elemTyp)
 		slcVal := val.Elem()
 		for rd.Next() {
 			elmVal := sliceNextElem(slcVal)
@@ -452,7 +452,7 @@ func (rd *read) readToSliceValue(v reflect.Value) (err error) {
 func (rd *read) getSchame(t reflect.Type) *schema {
 	s, ok := rd.schameMap[t]
 	if !ok {
-		s = newSchema(t)
+		s = loadSchema(t)
 		rd.schameMap[t] = s
 	}
 	return s
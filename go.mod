@@ -5,8 +5,12 @@ go 1.18
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/tjfoc/gmsm v1.3.2
+	golang.org/x/crypto v0.24.0
 	golang.org/x/image v0.0.0-20200430140353-33d19683fad8
-	golang.org/x/net v0.0.0-20190522155817-f3200d17e092
-	golang.org/x/text v0.3.2
+	golang.org/x/net v0.21.0
+	golang.org/x/text v0.16.0
 	gopkg.in/yaml.v2 v2.4.0
 )
+
+require golang.org/x/sys v0.21.0 // indirect
@@ -0,0 +1,304 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMissingClaim is returned when a claim required by a validator is missing.
+	JwtErrMissingClaim = errors.New("jwt: missing claim")
+	// ErrInvalidClaim is returned when a claim doesn't have the value required by a validator.
+	JwtErrInvalidClaim = errors.New("jwt: invalid claim")
+	// ErrInsufficientScope is returned when the token lacks a scope required by RequireScopes.
+	JwtErrInsufficientScope = errors.New("jwt: insufficient scope")
+	// ErrLegacyClaims is returned when typed claims are used with JwtModeLegacy,
+	// whose "exp" is relative to "iat".
+	JwtErrLegacyClaims = errors.New("jwt: typed claims need JwtModeRFC7519")
+)
+
+// JwtClaimError is the error of a rejected claim, Err is JwtErrInvalidReservedClaim
+// for "aud", "iss" and "sub" in RFC 7519 mode, JwtErrMissingClaim, JwtErrInvalidClaim
+// or JwtErrInsufficientScope for validators: errors.Is(err, JwtErrInsufficientScope).
+type JwtClaimError struct {
+	Claim string
+	Err   error
+	// Value is the missing scope, or the expected value of the claim
+	Value interface{}
+}
+
+func (err *JwtClaimError) Error() string {
+	if err.Value != nil {
+		return fmt.Sprintf("%s %s: %v", err.Err, err.Claim, err.Value)
+	}
+	return fmt.Sprintf("%s %s", err.Err, err.Claim)
+}
+
+func (err *JwtClaimError) Unwrap() error {
+	return err.Err
+}
+
+// JwtValidator checks the claims of a token whose signature is valid, it is
+// run by Verify from JwtVerifyOption.Validators. It returns a *JwtClaimError
+// or its own errors, which Verify returns as they are.
+type JwtValidator func(header JwtHeader, payload JwtPayload) error
+
+func runValidators(header JwtHeader, payload JwtPayload, validators []JwtValidator) error {
+	for _, validate := range validators {
+		if err := validate(header, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RequireScopes requires the scopes of OAuth 2.0 access tokens, in "scope",
+// space-separated (RFC 8693), or in "scp", an array or a string.
+func RequireScopes(scopes ...string) JwtValidator {
+	return func(header JwtHeader, payload JwtPayload) error {
+		granted, ok := payload.scopes()
+		if !ok {
+			return &JwtClaimError{Claim: "scope", Err: JwtErrMissingClaim}
+		}
+
+		for _, scope := range scopes {
+			if !granted[scope] {
+				return &JwtClaimError{Claim: "scope", Err: JwtErrInsufficientScope, Value: scope}
+			}
+		}
+
+		return nil
+	}
+}
+
+func (p JwtPayload) scopes() (map[string]bool, bool) {
+	var names []string
+
+	switch scope := p["scope"].(type) {
+	case string:
+		names = strings.Fields(scope)
+	default:
+		switch scp := p["scp"].(type) {
+		case string:
+			names = strings.Fields(scp)
+		case []interface{}:
+			for _, s := range scp {
+				if name, ok := s.(string); ok {
+					names = append(names, name)
+				}
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	granted := make(map[string]bool, len(names))
+	for _, name := range names {
+		granted[name] = true
+	}
+
+	return granted, true
+}
+
+// RequireClaim requires a claim to be expected, e.g. the tenant of a multi-tenant
+// service: RequireClaim("tid", tenantID). expected is compared with the claim
+// once encoded in JSON, so that 42 matches the float64 of the payload.
+func RequireClaim(name string, expected interface{}) JwtValidator {
+	want, err := json.Marshal(expected)
+
+	return func(header JwtHeader, payload JwtPayload) error {
+		if err != nil {
+			return err
+		}
+
+		v, ok := payload[name]
+		if !ok {
+			return &JwtClaimError{Claim: name, Err: JwtErrMissingClaim}
+		}
+
+		got, err := json.Marshal(v)
+		if err != nil || !bytes.Equal(got, want) {
+			return &JwtClaimError{Claim: name, Err: JwtErrInvalidClaim, Value: expected}
+		}
+
+		return nil
+	}
+}
+
+// JwtNumericDate is a time encoded as seconds since the epoch, as "exp",
+// "nbf" and "iat" of RFC 7519.
+type JwtNumericDate struct {
+	time.Time
+}
+
+// NewNumericDate returns the NumericDate of t, truncated to the second.
+func NewNumericDate(t time.Time) *JwtNumericDate {
+	return &JwtNumericDate{t.Truncate(time.Second)}
+}
+
+func (d JwtNumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprint(d.Unix())), nil
+}
+
+func (d *JwtNumericDate) UnmarshalJSON(data []byte) error {
+	var seconds float64
+
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return JwtErrInvalidReservedClaim
+	}
+
+	d.Time = time.Unix(0, int64(seconds*1e9))
+
+	return nil
+}
+
+// JwtAudience is "aud", a string or an array in JSON.
+type JwtAudience []string
+
+func (a JwtAudience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *JwtAudience) UnmarshalJSON(data []byte) error {
+	var aud interface{}
+
+	if err := json.Unmarshal(data, &aud); err != nil {
+		return err
+	}
+
+	switch aud := aud.(type) {
+	case string:
+		*a = JwtAudience{aud}
+	case []interface{}:
+		*a = make(JwtAudience, 0, len(aud))
+		for _, v := range aud {
+			s, ok := v.(string)
+			if !ok {
+				return JwtErrInvalidReservedClaim
+			}
+			*a = append(*a, s)
+		}
+	case nil:
+		*a = nil
+	default:
+		return JwtErrInvalidReservedClaim
+	}
+
+	return nil
+}
+
+// Contains reports whether aud is one of the audiences.
+func (a JwtAudience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JwtRegisteredClaims are the claims of RFC 7519, embedded in the claims
+// structs of SignClaims and VerifyClaims:
+//
+//	type AccessClaims struct {
+//		jwt.JwtRegisteredClaims
+//		TenantID string   `json:"tid"`
+//		Roles    []string `json:"roles,omitempty"`
+//	}
+type JwtRegisteredClaims struct {
+	Issuer    string          `json:"iss,omitempty"`
+	Subject   string          `json:"sub,omitempty"`
+	Audience  JwtAudience     `json:"aud,omitempty"`
+	ExpiresAt *JwtNumericDate `json:"exp,omitempty"`
+	NotBefore *JwtNumericDate `json:"nbf,omitempty"`
+	IssuedAt  *JwtNumericDate `json:"iat,omitempty"`
+	ID        string          `json:"jti,omitempty"`
+}
+
+// RegisteredClaims returns the registered claims, it makes the structs
+// embedding JwtRegisteredClaims JwtClaims.
+func (c *JwtRegisteredClaims) RegisteredClaims() *JwtRegisteredClaims {
+	return c
+}
+
+// JwtClaims is a pointer to a struct embedding JwtRegisteredClaims.
+// Structs which also have a `Validate() error` method are validated by
+// VerifyClaims after the validators of the option.
+type JwtClaims interface {
+	RegisteredClaims() *JwtRegisteredClaims
+}
+
+type claimsValidator interface {
+	Validate() error
+}
+
+// SignClaims signs the claims of a struct embedding JwtRegisteredClaims, as Sign.
+// The claims set by opt win over the ones of the struct, "iat" is always the
+// current time.
+func (jwt *XPJwtImpl) SignClaims(claims JwtClaims, secret interface{}, opt *JwtSignOption) ([]byte, error) {
+	if jwt.Mode != JwtModeRFC7519 {
+		return nil, JwtErrLegacyClaims
+	}
+
+	if claims == nil || reflect.ValueOf(claims).IsNil() {
+		return nil, JwtErrEmptyPayload
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload JwtPayload
+
+	// json.Number keeps the integers larger than 2^53
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	if payload == nil {
+		return nil, JwtErrEmptyPayload
+	}
+
+	return jwt.Sign(payload, secret, opt)
+}
+
+// VerifyClaims verifies the token as Verify and decodes its payload into
+// claims, a pointer to a struct embedding JwtRegisteredClaims, which is then
+// validated if it has a Validate method.
+func (jwt *XPJwtImpl) VerifyClaims(token []byte, secret interface{}, claims JwtClaims, opt *JwtVerifyOption) (JwtHeader, error) {
+	if jwt.Mode != JwtModeRFC7519 {
+		return nil, JwtErrLegacyClaims
+	}
+
+	s, err := jwt.verify(token, secret, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(s.payloadJSON, claims); err != nil {
+		return nil, err
+	}
+
+	if v, ok := claims.(claimsValidator); ok {
+		if err = v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.header, nil
+}
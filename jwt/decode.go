@@ -6,33 +6,46 @@ import (
 	"encoding/json"
 )
 
-func decode(token []byte) (header map[string]interface{}, payload map[string]interface{}, err error) {
-	segments := bytes.Split(token, periodBytes)
+// token 的各个部分，signingInput 为签名的内容 header.payload，payloadJSON 为解码后的 payload
+type segments struct {
+	header       JwtHeader
+	payload      JwtPayload
+	payloadJSON  []byte
+	signature    []byte
+	signingInput []byte
+}
+
+func decode(token []byte, enc *base64.Encoding) (s segments, err error) {
+	parts := bytes.Split(token, periodBytes)
 
-	if len(segments) != 3 {
-		return nil, nil, JwtErrInvalidToken
+	if len(parts) != 3 {
+		return s, JwtErrInvalidToken
 	}
 
-	if header, err = decodeSegment(segments[0]); err != nil {
-		return nil, nil, err
+	if s.header, _, err = decodeSegment(parts[0], enc); err != nil {
+		return s, err
 	}
 
-	if payload, err = decodeSegment(segments[1]); err != nil {
-		return nil, nil, err
+	if s.payload, s.payloadJSON, err = decodeSegment(parts[1], enc); err != nil {
+		return s, err
 	}
 
-	return header, payload, nil
-}
+	if s.signature, err = enc.DecodeString(string(parts[2])); err != nil {
+		return s, err
+	}
+
+	s.signingInput = token[0:bytes.LastIndexByte(token, '.')]
 
-func decodeSegment(segment []byte) (m map[string]interface{}, err error) {
-	s, err := base64.StdEncoding.DecodeString(string(segment))
+	return s, nil
+}
 
-	if err != nil {
-		return nil, err
+func decodeSegment(segment []byte, enc *base64.Encoding) (m map[string]interface{}, s []byte, err error) {
+	if s, err = enc.DecodeString(string(segment)); err != nil {
+		return nil, nil, err
 	}
 
-	if err := json.Unmarshal(s, &m); err != nil {
-		return nil, err
+	if err = json.Unmarshal(s, &m); err != nil {
+		return nil, nil, err
 	}
 
 	return
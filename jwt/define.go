@@ -2,9 +2,24 @@ package jwt
 
 import (
 	"errors"
+	"strings"
+	"sync"
 	"time"
 )
 
+// JwtMode is the format of the tokens signed and verified by XPJwtImpl.
+type JwtMode int
+
+const (
+	// JwtModeLegacy is the format of the first versions: padded standard base64
+	// and exp relative to iat, in seconds. It is the zero value, for XPJwtImpl{}.
+	JwtModeLegacy JwtMode = iota
+	// JwtModeRFC7519 is the format of RFC 7519, used by other JWT libraries:
+	// base64url without padding, exp, nbf and iat as NumericDate (seconds since
+	// the epoch) and aud as a string or an array. NewJwt uses it.
+	JwtModeRFC7519
+)
+
 // Algorithm represents a supported hash algorithms.
 type JwtAlgorithm string
 
@@ -21,6 +36,20 @@ const (
 	JwtRS384 JwtAlgorithm = "RS384"
 	// RS512 represents RSASSA using SHA-512 hash algorithm.
 	JwtRS512 JwtAlgorithm = "RS512"
+	// ES256 represents ECDSA using P-256 and SHA-256 hash algorithm.
+	JwtES256 JwtAlgorithm = "ES256"
+	// ES384 represents ECDSA using P-384 and SHA-384 hash algorithm.
+	JwtES384 JwtAlgorithm = "ES384"
+	// ES512 represents ECDSA using P-521 and SHA-512 hash algorithm.
+	JwtES512 JwtAlgorithm = "ES512"
+	// PS256 represents RSASSA-PSS using SHA-256 hash algorithm.
+	JwtPS256 JwtAlgorithm = "PS256"
+	// PS384 represents RSASSA-PSS using SHA-384 hash algorithm.
+	JwtPS384 JwtAlgorithm = "PS384"
+	// PS512 represents RSASSA-PSS using SHA-512 hash algorithm.
+	JwtPS512 JwtAlgorithm = "PS512"
+	// EdDSA represents EdDSA using Ed25519.
+	JwtEdDSA JwtAlgorithm = "EdDSA"
 )
 
 var (
@@ -50,14 +79,56 @@ var (
 	JwtErrPayloadMissingExp = errors.New("jwt: payload missing exp")
 	// ErrTokenExpired is returned when the token is expired.
 	JwtErrTokenExpired = errors.New("jwt: token expired")
+	// ErrTokenNotValidYet is returned when "nbf" is in the future.
+	JwtErrTokenNotValidYet = errors.New("jwt: token not valid yet")
 
 	periodBytes = []byte(".")
 	algImpMap   = map[JwtAlgorithm]algorithmImplementation{}
+	algImpMutex sync.RWMutex
 )
 
 type algorithmImplementation interface {
 	sign(content []byte, key interface{}) ([]byte, error)
-	verify(signing []byte, key interface{}) (JwtHeader, JwtPayload, error)
+	verify(content, signature []byte, key interface{}) error
+}
+
+// JwtSigner implements an algorithm registered with RegisterAlgorithm.
+// Sign returns the signature of content, the "header.payload" of the token,
+// Verify returns JwtErrInvalidSignature when signature doesn't match and
+// JwtErrInvalidKeyType for keys of the wrong type.
+type JwtSigner interface {
+	Sign(content []byte, key interface{}) ([]byte, error)
+	Verify(content, signature []byte, key interface{}) error
+}
+
+type signerAlgImp struct {
+	signer JwtSigner
+}
+
+func (sa signerAlgImp) sign(content []byte, key interface{}) ([]byte, error) {
+	return sa.signer.Sign(content, key)
+}
+
+func (sa signerAlgImp) verify(content, signature []byte, key interface{}) error {
+	return sa.signer.Verify(content, signature, key)
+}
+
+// RegisterAlgorithm adds an algorithm, e.g. SM2 for Chinese compliance, or
+// replaces the implementation of a built-in one.
+func RegisterAlgorithm(alg JwtAlgorithm, signer JwtSigner) {
+	algImpMutex.Lock()
+	defer algImpMutex.Unlock()
+
+	algImpMap[alg] = signerAlgImp{signer: signer}
+}
+
+func algorithm(alg JwtAlgorithm) (algorithmImplementation, bool) {
+	algImpMutex.RLock()
+	defer algImpMutex.RUnlock()
+
+	ai, ok := algImpMap[alg]
+
+	return ai, ok
 }
 
 // Header represents a JWT header.
@@ -81,6 +152,29 @@ func (h JwtHeader) hasValidType() bool {
 	return received == "JWT"
 }
 
+// hasStandardType accepts a missing "typ" and the types of RFC 7519 and
+// RFC 9068, e.g. "JWT" and "at+jwt", ignoring case. The refresh tokens of
+// JwtRefreshRotator are accepted only, and always, when refresh is set.
+func (h JwtHeader) hasStandardType(refresh bool) bool {
+	typ, ok := h["typ"]
+	if !ok {
+		return !refresh
+	}
+
+	received, ok := typ.(string)
+	if !ok {
+		return false
+	}
+
+	received = strings.TrimPrefix(strings.ToLower(received), "application/")
+
+	if received == refreshTokenType {
+		return refresh
+	}
+
+	return !refresh && (received == "jwt" || strings.HasSuffix(received, "+jwt"))
+}
+
 type JwtPayload map[string]interface{}
 
 func (p JwtPayload) checkStringClaim(key, expected string) bool {
@@ -153,3 +247,72 @@ func (p JwtPayload) checkExpiration(timeout time.Duration) bool {
 
 	return false
 }
+
+// numericDate returns the NumericDate claim key, ok is false if it is missing.
+func (p JwtPayload) numericDate(key string) (t time.Time, ok bool, err error) {
+	v, ok := p[key]
+	if !ok {
+		return t, false, nil
+	}
+
+	seconds, isNumber := v.(float64)
+	if !isNumber {
+		return t, true, JwtErrInvalidReservedClaim
+	}
+
+	return time.Unix(0, int64(seconds*1e9)), true, nil
+}
+
+// hasAudience reports whether "aud", a string or an array, contains expected.
+func (p JwtPayload) hasAudience(expected string) bool {
+	if expected == "" {
+		return true
+	}
+
+	switch aud := p["aud"].(type) {
+	case string:
+		return aud == expected
+	case []interface{}:
+		for _, a := range aud {
+			if a == expected {
+				return true
+			}
+		}
+	case []string:
+		for _, a := range aud {
+			if a == expected {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkTimes checks "exp" and "nbf" of an RFC 7519 payload.
+func (p JwtPayload) checkTimes(opt *JwtVerifyOption) error {
+	now := time.Now()
+
+	if !opt.IngoreExpiration {
+		exp, ok, err := p.numericDate("exp")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return JwtErrPayloadMissingExp
+		}
+		if !now.Add(opt.Timeout - opt.Leeway).Before(exp) {
+			return JwtErrTokenExpired
+		}
+	}
+
+	nbf, ok, err := p.numericDate("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(opt.Leeway).Before(nbf) {
+		return JwtErrTokenNotValidYet
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xpsuper/stl/memorycache"
+)
+
+var (
+	// ErrTokenRevoked is returned when the "jti" of a token is in the denylist,
+	// or when its refresh token family was revoked.
+	JwtErrTokenRevoked = errors.New("jwt: token revoked")
+)
+
+// JwtDenylist holds the "jti" of tokens revoked before their expiration, it is
+// checked by Verify when set in JwtVerifyOption.Denylist. Implementations
+// shared by several instances of a service store it in Redis or a database.
+type JwtDenylist interface {
+	// Revoke adds jti to the list until expiresAt, the expiration of the token,
+	// or for good when expiresAt is zero, for tokens without expiration
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+// JwtMemoryDenylist is a JwtDenylist of one process on a memorycache.Cache,
+// each jti is kept for the remaining life of its token, plus Leeway.
+type JwtMemoryDenylist struct {
+	// Leeway should be the JwtVerifyOption.Leeway of Verify, which accepts
+	// tokens expired for less than Leeway
+	Leeway time.Duration
+	// MaxTTL bounds how long the tokens without expiration are kept, they are
+	// kept until evicted when 0
+	MaxTTL time.Duration
+
+	cache *memorycache.Cache
+}
+
+// NewMemoryDenylist creates a memory denylist on cache, which may be shared
+// with other data, or on a cache of a million tokens if nil.
+// The least recently used tokens are evicted from a full cache, and accepted
+// again by Verify: MaxSize must exceed the number of revoked unexpired tokens.
+func NewMemoryDenylist(cache *memorycache.Cache) *JwtMemoryDenylist {
+	if cache == nil {
+		cache = memorycache.NewCache(memorycache.Configure().MaxSize(1 << 20))
+	}
+
+	return &JwtMemoryDenylist{cache: cache}
+}
+
+func (d *JwtMemoryDenylist) Revoke(jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return &JwtClaimError{Claim: "jti", Err: JwtErrMissingClaim}
+	}
+
+	if expiresAt.IsZero() {
+		ttl := d.MaxTTL
+		if ttl <= 0 {
+			ttl = denylistForever
+		}
+		d.cache.Set(denylistKey(jti), true, ttl)
+		return nil
+	}
+
+	if ttl := time.Until(expiresAt) + d.Leeway; ttl > 0 {
+		d.cache.Set(denylistKey(jti), true, ttl)
+	}
+
+	return nil
+}
+
+func (d *JwtMemoryDenylist) IsRevoked(jti string) (bool, error) {
+	item := d.cache.Get(denylistKey(jti))
+
+	return item != nil && !item.Expired(), nil
+}
+
+// denylistForever is the TTL of tokens without expiration, the expiration
+// of a cache item must fit in int64 nanoseconds
+const denylistForever = 100 * 365 * 24 * time.Hour
+
+func denylistKey(jti string) string {
+	return "jwt:revoked:" + jti
+}
+
+// Revoke adds the "jti" of a verified payload to the denylist until its "exp",
+// or for good when the token has no "exp". Tokens without "jti" can't be revoked.
+func (jwt *XPJwtImpl) Revoke(list JwtDenylist, payload JwtPayload) error {
+	jti, _ := payload["jti"].(string)
+	if jti == "" {
+		return &JwtClaimError{Claim: "jti", Err: JwtErrMissingClaim}
+	}
+
+	if _, ok := payload["exp"]; !ok {
+		return list.Revoke(jti, time.Time{})
+	}
+
+	exp, err := jwt.expiresAt(payload)
+	if err != nil {
+		return err
+	}
+
+	return list.Revoke(jti, exp)
+}
+
+// expiresAt is the "exp" of the payload, relative to "iat" in JwtModeLegacy
+func (jwt *XPJwtImpl) expiresAt(payload JwtPayload) (time.Time, error) {
+	if jwt.Mode != JwtModeRFC7519 {
+		return payload.expTime()
+	}
+
+	exp, ok, err := payload.numericDate("exp")
+	if err == nil && !ok {
+		err = JwtErrPayloadMissingExp
+	}
+
+	return exp, err
+}
+
+// checkDenylist rejects the revoked tokens and, since they can't be revoked,
+// the tokens without "jti"
+func (p JwtPayload) checkDenylist(list JwtDenylist) error {
+	jti, _ := p["jti"].(string)
+	if jti == "" {
+		return &JwtClaimError{Claim: "jti", Err: JwtErrMissingClaim}
+	}
+
+	revoked, err := list.IsRevoked(jti)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return JwtErrTokenRevoked
+	}
+
+	return nil
+}
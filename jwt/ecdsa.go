@@ -0,0 +1,86 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+)
+
+func init() {
+	algImpMap[JwtES256] = ecdsaAlgImp{hash: crypto.SHA256, curve: elliptic.P256()}
+	algImpMap[JwtES384] = ecdsaAlgImp{hash: crypto.SHA384, curve: elliptic.P384()}
+	algImpMap[JwtES512] = ecdsaAlgImp{hash: crypto.SHA512, curve: elliptic.P521()}
+}
+
+// ecdsaAlgImp 的签名为 RFC 7518 定义的 R || S，各占曲线的字节长度
+type ecdsaAlgImp struct {
+	hash  crypto.Hash
+	curve elliptic.Curve
+}
+
+func (ea ecdsaAlgImp) size() int {
+	return (ea.curve.Params().BitSize + 7) / 8
+}
+
+func (ea ecdsaAlgImp) sign(content []byte, privateKey interface{}) ([]byte, error) {
+	key, ok := privateKey.(*ecdsa.PrivateKey)
+
+	if !ok || key.Curve != ea.curve {
+		return nil, JwtErrInvalidKeyType
+	}
+
+	h := ea.hash.New()
+
+	h.Write(content)
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+
+	if err != nil {
+		return nil, err
+	}
+
+	size := ea.size()
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signature, nil
+}
+
+func (ea ecdsaAlgImp) verify(content, signature []byte, key interface{}) error {
+	var publicKey *ecdsa.PublicKey
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		publicKey = k
+	case *ecdsa.PrivateKey:
+		publicKey = &k.PublicKey
+	default:
+		return JwtErrInvalidKeyType
+	}
+
+	if publicKey.Curve != ea.curve {
+		return JwtErrInvalidKeyType
+	}
+
+	size := ea.size()
+
+	if len(signature) != 2*size {
+		return JwtErrInvalidSignature
+	}
+
+	h := ea.hash.New()
+
+	h.Write(content)
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+
+	if !ecdsa.Verify(publicKey, h.Sum(nil), r, s) {
+		return JwtErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+)
+
+func init() {
+	algImpMap[JwtEdDSA] = eddsaAlgImp{}
+}
+
+// eddsaAlgImp 支持 Ed25519 密钥
+type eddsaAlgImp struct{}
+
+func (eddsaAlgImp) sign(content []byte, privateKey interface{}) ([]byte, error) {
+	var key ed25519.PrivateKey
+
+	switch k := privateKey.(type) {
+	case ed25519.PrivateKey:
+		key = k
+	case *ed25519.PrivateKey:
+		key = *k
+	default:
+		return nil, JwtErrInvalidKeyType
+	}
+
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, JwtErrInvalidKeyType
+	}
+
+	return ed25519.Sign(key, content), nil
+}
+
+func (eddsaAlgImp) verify(content, signature []byte, key interface{}) error {
+	var publicKey ed25519.PublicKey
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		publicKey = k
+	case *ed25519.PublicKey:
+		publicKey = *k
+	case ed25519.PrivateKey:
+		publicKey, _ = k.Public().(ed25519.PublicKey)
+	case *ed25519.PrivateKey:
+		publicKey, _ = k.Public().(ed25519.PublicKey)
+	default:
+		return JwtErrInvalidKeyType
+	}
+
+	if len(publicKey) != ed25519.PublicKeySize {
+		return JwtErrInvalidKeyType
+	}
+
+	if !ed25519.Verify(publicKey, content, signature) {
+		return JwtErrInvalidSignature
+	}
+
+	return nil
+}
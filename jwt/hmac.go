@@ -1,10 +1,10 @@
 package jwt
 
 import (
-	"bytes"
 	"crypto"
 	"crypto/hmac"
-	"encoding/base64"
+	_ "crypto/sha256" // 注册 crypto.SHA256，hmac 和 rsa 算法使用
+	_ "crypto/sha512" // 注册 crypto.SHA384 和 crypto.SHA512
 	"hash"
 )
 
@@ -37,28 +37,16 @@ func (ha hmacAlgImp) sign(content []byte, secret interface{}) ([]byte, error) {
 	return h.Sum(nil), nil
 }
 
-func (ha hmacAlgImp) verify(token []byte, secret interface{}) (header JwtHeader, payload JwtPayload, err error) {
-	if header, payload, err = decode(token); err != nil {
-		return
-	}
-
-	signatureReceive, err := base64.StdEncoding.DecodeString(string(bytes.Split(token, periodBytes)[2]))
-
-	if err != nil {
-		return
-	}
-
-	signatureExpect, err := ha.sign(token[0:bytes.LastIndexByte(token, '.')], secret)
+func (ha hmacAlgImp) verify(content, signature []byte, secret interface{}) error {
+	signatureExpect, err := ha.sign(content, secret)
 
 	if err != nil {
-		return
+		return err
 	}
 
-	ok := hmac.Equal(signatureExpect, signatureReceive)
-
-	if !ok {
-		return nil, nil, JwtErrInvalidSignature
+	if !hmac.Equal(signatureExpect, signature) {
+		return JwtErrInvalidSignature
 	}
 
-	return
-}
\ No newline at end of file
+	return nil
+}
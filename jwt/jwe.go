@@ -0,0 +1,450 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// JweKeyAlgorithm is the "alg" of an encrypted token, how the content
+// encryption key is shared with the recipient.
+type JweKeyAlgorithm string
+
+// JweEncryption is the "enc" of an encrypted token, how the content is encrypted.
+type JweEncryption string
+
+const (
+	// RSA-OAEP represents RSAES OAEP using SHA-1, the key is a *rsa.PublicKey.
+	JweRSAOAEP JweKeyAlgorithm = "RSA-OAEP"
+	// RSA-OAEP-256 represents RSAES OAEP using SHA-256, the key is a *rsa.PublicKey.
+	JweRSAOAEP256 JweKeyAlgorithm = "RSA-OAEP-256"
+	// dir represents the direct use of a shared key as content encryption key,
+	// the key is a []byte of the size of the encryption key.
+	JweDirect JweKeyAlgorithm = "dir"
+	// A256KW represents AES Key Wrap of the content encryption key with a
+	// shared 256 bits []byte key.
+	JweA256KW JweKeyAlgorithm = "A256KW"
+
+	// A128GCM represents AES GCM using a 128 bits key.
+	JweA128GCM JweEncryption = "A128GCM"
+	// A256GCM represents AES GCM using a 256 bits key.
+	JweA256GCM JweEncryption = "A256GCM"
+	// A128CBC-HS256 represents AES CBC and HMAC SHA-256 using a 256 bits key.
+	JweA128CBCHS256 JweEncryption = "A128CBC-HS256"
+)
+
+var (
+	// ErrInvalidJWE is returned when an encrypted token is malformed.
+	JwtErrInvalidJWE = errors.New("jwt: invalid encrypted token")
+	// ErrDecryption is returned when an encrypted token can't be decrypted,
+	// because of the key or a modified token.
+	JwtErrDecryption = errors.New("jwt: decryption failed")
+	// ErrUnsupportedHeader is returned when an encrypted token is compressed
+	// or has critical extensions, which Decrypt doesn't understand.
+	JwtErrUnsupportedHeader = errors.New("jwt: unsupported header parameter")
+)
+
+// JweOption is the option of Encrypt and the expected algorithms of Decrypt.
+type JweOption struct {
+	Algorithm   JweKeyAlgorithm //密钥管理算法，默认 RSA-OAEP-256
+	Encryption  JweEncryption   //内容加密算法，默认 A256GCM
+	ContentType string          //内容类型 cty，嵌套 token 为 JWT
+	Header      JwtHeader       //自定义的头，将被合并至 Token 的头部
+}
+
+func (e JweEncryption) keySize() int {
+	switch e {
+	case JweA128GCM:
+		return 16
+	case JweA256GCM, JweA128CBCHS256:
+		return 32
+	}
+	return 0
+}
+
+// 加密内容，返回 JWE compact 格式的 token，无论 Mode 都使用 base64url
+// key 为 rsa.PublicKey (RSA-OAEP, RSA-OAEP-256) 或 []byte (dir, A256KW) 或 JwtKey
+// 如 stl.GetPublicKey 读取的公钥
+func (jwt *XPJwtImpl) Encrypt(plaintext []byte, key interface{}, opt *JweOption) (token []byte, err error) {
+	if opt == nil {
+		opt = &JweOption{}
+	}
+
+	o := *opt
+
+	if o.Algorithm == "" {
+		o.Algorithm = JweRSAOAEP256
+	}
+
+	if o.Encryption == "" {
+		o.Encryption = JweA256GCM
+	}
+
+	size := o.Encryption.keySize()
+
+	if size == 0 {
+		return nil, JwtErrInvalidAlgorithm
+	}
+
+	header := JwtHeader{}
+
+	if k, ok := key.(*JwtKey); ok {
+		if k.ID != "" {
+			header["kid"] = k.ID
+		}
+		key = k.Key
+	}
+
+	for name, v := range o.Header {
+		header[name] = v
+	}
+
+	header["alg"], header["enc"] = o.Algorithm, o.Encryption
+
+	if o.ContentType != "" {
+		header["cty"] = o.ContentType
+	}
+
+	cek, encryptedKey, err := wrapKey(o.Algorithm, key, size)
+	if err != nil {
+		return nil, err
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := base64.RawURLEncoding
+	aad := []byte(enc.EncodeToString(headerJSON))
+
+	iv, ciphertext, tag, err := encryptContent(o.Encryption, cek, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.Join([][]byte{
+		aad,
+		[]byte(enc.EncodeToString(encryptedKey)),
+		[]byte(enc.EncodeToString(iv)),
+		[]byte(enc.EncodeToString(ciphertext)),
+		[]byte(enc.EncodeToString(tag)),
+	}, periodBytes), nil
+}
+
+// 解密 JWE compact 格式的 token，返回 header 和内容
+// key 为 rsa.PrivateKey (RSA-OAEP, RSA-OAEP-256) 或 []byte (dir, A256KW) 或 JwtKey，如 stl.GetPrivateKey 读取的私钥
+// opt 的 Algorithm 和 Encryption 不为空时 token 必须使用这些算法
+func (jwt *XPJwtImpl) Decrypt(token []byte, key interface{}, opt *JweOption) (header JwtHeader, plaintext []byte, err error) {
+	if opt == nil {
+		opt = &JweOption{}
+	}
+
+	parts := bytes.Split(token, periodBytes)
+
+	if len(parts) != 5 {
+		return nil, nil, JwtErrInvalidJWE
+	}
+
+	enc := base64.RawURLEncoding
+	decoded := make([][]byte, 5)
+
+	for i, part := range parts {
+		if decoded[i], err = enc.DecodeString(string(part)); err != nil {
+			return nil, nil, JwtErrInvalidJWE
+		}
+	}
+
+	if err = json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, nil, JwtErrInvalidJWE
+	}
+
+	// RFC 7516 §4.1.3 and RFC 7515 §4.1.11, what can't be processed must be rejected
+	if zip, ok := header["zip"]; ok {
+		return nil, nil, fmt.Errorf("%w: zip %v", JwtErrUnsupportedHeader, zip)
+	}
+	if crit, ok := header["crit"]; ok {
+		return nil, nil, fmt.Errorf("%w: crit %v", JwtErrUnsupportedHeader, crit)
+	}
+
+	alg, _ := header["alg"].(string)
+	encryption, _ := header["enc"].(string)
+
+	if opt.Algorithm != "" && JweKeyAlgorithm(alg) != opt.Algorithm ||
+		opt.Encryption != "" && JweEncryption(encryption) != opt.Encryption {
+		return nil, nil, JwtErrInvalidAlgorithm
+	}
+
+	size := JweEncryption(encryption).keySize()
+
+	if size == 0 {
+		return nil, nil, JwtErrInvalidAlgorithm
+	}
+
+	if k, ok := key.(*JwtKey); ok {
+		key = k.Key
+	}
+
+	cek, err := unwrapKey(JweKeyAlgorithm(alg), key, decoded[1], size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if plaintext, err = decryptContent(JweEncryption(encryption), cek, decoded[2], decoded[3], decoded[4], parts[0]); err != nil {
+		return nil, nil, err
+	}
+
+	return header, plaintext, nil
+}
+
+// 签名后加密的嵌套 token：先用 signKey 签名，再将签名的 token 作为内容加密，cty 为 JWT
+func (jwt *XPJwtImpl) SignEncrypt(payload JwtPayload, signKey interface{}, signOpt *JwtSignOption, encryptKey interface{}, encryptOpt *JweOption) ([]byte, error) {
+	signed, err := jwt.Sign(payload, signKey, signOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	o := JweOption{}
+
+	if encryptOpt != nil {
+		o = *encryptOpt
+	}
+
+	o.ContentType = "JWT"
+
+	return jwt.Encrypt(signed, encryptKey, &o)
+}
+
+// 解密并验证 SignEncrypt 生成的嵌套 token，返回内层签名 token 的 header 和 payload
+func (jwt *XPJwtImpl) DecryptVerify(token []byte, decryptKey interface{}, decryptOpt *JweOption, verifyKey interface{}, verifyOpt *JwtVerifyOption) (JwtHeader, JwtPayload, error) {
+	header, signed, err := jwt.Decrypt(token, decryptKey, decryptOpt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cty, _ := header["cty"].(string); !strings.EqualFold(cty, "JWT") {
+		return nil, nil, JwtErrInvalidHeaderType
+	}
+
+	return jwt.Verify(signed, verifyKey, verifyOpt)
+}
+
+func wrapKey(alg JweKeyAlgorithm, key interface{}, size int) (cek, encryptedKey []byte, err error) {
+	switch alg {
+	case JweRSAOAEP, JweRSAOAEP256:
+		var publicKey *rsa.PublicKey
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			publicKey = k
+		case *rsa.PrivateKey:
+			publicKey = &k.PublicKey
+		default:
+			return nil, nil, JwtErrInvalidKeyType
+		}
+		cek = make([]byte, size)
+		if _, err = rand.Read(cek); err != nil {
+			return nil, nil, err
+		}
+		encryptedKey, err = rsa.EncryptOAEP(oaepHash(alg), rand.Reader, publicKey, cek, nil)
+		return cek, encryptedKey, err
+	case JweDirect:
+		secret, ok := key.([]byte)
+		if !ok || len(secret) != size {
+			return nil, nil, JwtErrInvalidKeyType
+		}
+		return secret, nil, nil
+	case JweA256KW:
+		kek, ok := key.([]byte)
+		if !ok || len(kek) != 32 {
+			return nil, nil, JwtErrInvalidKeyType
+		}
+		cek = make([]byte, size)
+		if _, err = rand.Read(cek); err != nil {
+			return nil, nil, err
+		}
+		encryptedKey, err = keyWrap(kek, cek)
+		return cek, encryptedKey, err
+	}
+
+	return nil, nil, JwtErrInvalidAlgorithm
+}
+
+func unwrapKey(alg JweKeyAlgorithm, key interface{}, encryptedKey []byte, size int) ([]byte, error) {
+	switch alg {
+	case JweRSAOAEP, JweRSAOAEP256:
+		privateKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, JwtErrInvalidKeyType
+		}
+		cek, err := rsa.DecryptOAEP(oaepHash(alg), rand.Reader, privateKey, encryptedKey, nil)
+		if err != nil || len(cek) != size {
+			// RFC 7516 11.5: a random key makes the failure indistinguishable from a bad tag
+			cek = make([]byte, size)
+			if _, err = rand.Read(cek); err != nil {
+				return nil, err
+			}
+		}
+		return cek, nil
+	case JweDirect:
+		secret, ok := key.([]byte)
+		if !ok || len(secret) != size {
+			return nil, JwtErrInvalidKeyType
+		}
+		if len(encryptedKey) != 0 {
+			return nil, JwtErrInvalidJWE
+		}
+		return secret, nil
+	case JweA256KW:
+		kek, ok := key.([]byte)
+		if !ok || len(kek) != 32 {
+			return nil, JwtErrInvalidKeyType
+		}
+		cek, err := keyUnwrap(kek, encryptedKey)
+		if err != nil || len(cek) != size {
+			return nil, JwtErrDecryption
+		}
+		return cek, nil
+	}
+
+	return nil, JwtErrInvalidAlgorithm
+}
+
+func oaepHash(alg JweKeyAlgorithm) hash.Hash {
+	if alg == JweRSAOAEP256 {
+		return sha256.New()
+	}
+
+	return sha1.New()
+}
+
+func encryptContent(e JweEncryption, cek, plaintext, aad []byte) (iv, ciphertext, tag []byte, err error) {
+	block, err := aes.NewCipher(cek[len(cek)-e.aesKeySize():])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if e == JweA128CBCHS256 {
+		iv = make([]byte, aes.BlockSize)
+		if _, err = rand.Read(iv); err != nil {
+			return nil, nil, nil, err
+		}
+		padded := pkcs7Pad(plaintext, aes.BlockSize)
+		ciphertext = make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+		return iv, ciphertext, cbcTag(cek[:16], aad, iv, ciphertext), nil
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	iv = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(iv); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sealed := gcm.Seal(nil, iv, plaintext, aad)
+	split := len(sealed) - gcm.Overhead()
+
+	return iv, sealed[:split], sealed[split:], nil
+}
+
+func decryptContent(e JweEncryption, cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(cek[len(cek)-e.aesKeySize():])
+	if err != nil {
+		return nil, err
+	}
+
+	if e == JweA128CBCHS256 {
+		if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 ||
+			subtle.ConstantTimeCompare(cbcTag(cek[:16], aad, iv, ciphertext), tag) != 1 {
+			return nil, JwtErrDecryption
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+		if plaintext, err = pkcs7Unpad(plaintext, aes.BlockSize); err != nil {
+			return nil, JwtErrDecryption
+		}
+		return plaintext, nil
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return nil, JwtErrDecryption
+	}
+
+	plaintext, err := gcm.Open(nil, iv, append(append([]byte(nil), ciphertext...), tag...), aad)
+	if err != nil {
+		return nil, JwtErrDecryption
+	}
+
+	return plaintext, nil
+}
+
+// aesKeySize is the size of the AES key, the end of the content encryption
+// key: A128CBC-HS256 starts with the HMAC key.
+func (e JweEncryption) aesKeySize() int {
+	if e == JweA128CBCHS256 {
+		return 16
+	}
+
+	return e.keySize()
+}
+
+// cbcTag is the authentication tag of A128CBC-HS256, RFC 7518 5.2.2.1.
+func cbcTag(macKey, aad, iv, ciphertext []byte) []byte {
+	al := make([]byte, 8)
+	binary.BigEndian.PutUint64(al, uint64(len(aad))*8)
+
+	h := hmac.New(sha256.New, macKey)
+	h.Write(aad)
+	h.Write(iv)
+	h.Write(ciphertext)
+	h.Write(al)
+
+	return h.Sum(nil)[:16]
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, JwtErrDecryption
+	}
+
+	padding := int(data[len(data)-1])
+
+	if padding == 0 || padding > blockSize || padding > len(data) {
+		return nil, JwtErrDecryption
+	}
+
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, JwtErrDecryption
+		}
+	}
+
+	return data[:len(data)-padding], nil
+}
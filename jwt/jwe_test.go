@@ -0,0 +1,31 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecryptUnsupportedHeader(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	opt := &JweOption{Algorithm: JweDirect}
+	token, err := NewJwt().Encrypt([]byte("hello"), key, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, plaintext, err := NewJwt().Decrypt(token, key, opt); err != nil || string(plaintext) != "hello" {
+		t.Fatalf("Decrypt = %q, %v", plaintext, err)
+	}
+
+	rest := token[bytes.IndexByte(token, '.'):]
+	for _, header := range []string{
+		`{"alg":"dir","enc":"A256GCM","zip":"DEF"}`,
+		`{"alg":"dir","enc":"A256GCM","crit":["exp"],"exp":1}`,
+	} {
+		forged := append([]byte(base64.RawURLEncoding.EncodeToString([]byte(header))), rest...)
+		if _, _, err := NewJwt().Decrypt(forged, key, opt); !errors.Is(err, JwtErrUnsupportedHeader) {
+			t.Errorf("Decrypt with %s = %v, want JwtErrUnsupportedHeader", header, err)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	// ErrInvalidJWK is returned when a JWK is malformed or of an unsupported type.
+	JwtErrInvalidJWK = errors.New("jwt: invalid JWK")
+	// ErrKeyNotFound is returned when no key of a key set matches the "kid" of a token.
+	JwtErrKeyNotFound = errors.New("jwt: key not found")
+)
+
+// JwtKey is a key of a key set, a JSON Web Key (RFC 7517).
+// Key is a *rsa.PublicKey, *rsa.PrivateKey, *ecdsa.PublicKey, *ecdsa.PrivateKey,
+// ed25519.PublicKey, ed25519.PrivateKey or the []byte secret of HMAC algorithms.
+// It is passed as the key of Sign, which then sets the "kid" header and the
+// algorithm if the option doesn't.
+type JwtKey struct {
+	ID        string
+	Algorithm JwtAlgorithm
+	Use       string
+	Key       interface{}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	// RSA
+	N  string `json:"n,omitempty"`
+	E  string `json:"e,omitempty"`
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	Dp string `json:"dp,omitempty"`
+	Dq string `json:"dq,omitempty"`
+	Qi string `json:"qi,omitempty"`
+	// EC and OKP
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+	// oct
+	K string `json:"k,omitempty"`
+}
+
+// ParseJWK parses a JSON Web Key of type RSA, EC (P-256, P-384, P-521),
+// OKP (Ed25519) or oct, public or private.
+func ParseJWK(data []byte) (*JwtKey, error) {
+	var j jwk
+
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+
+	return j.key()
+}
+
+func (j *jwk) key() (*JwtKey, error) {
+	k := &JwtKey{ID: j.Kid, Algorithm: JwtAlgorithm(j.Alg), Use: j.Use}
+	var err error
+
+	switch j.Kty {
+	case "RSA":
+		k.Key, err = j.rsaKey()
+	case "EC":
+		k.Key, err = j.ecKey()
+	case "OKP":
+		k.Key, err = j.okpKey()
+	case "oct":
+		var secret []byte
+		if secret, err = decodeJWKField(j.K); err == nil && len(secret) == 0 {
+			err = JwtErrInvalidJWK
+		}
+		k.Key = secret
+	default:
+		err = JwtErrInvalidJWK
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s: %v", JwtErrInvalidJWK, j.Kty, j.Kid, err)
+	}
+
+	return k, nil
+}
+
+func decodeJWKField(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := decodeJWKField(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("missing parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (j *jwk) rsaKey() (interface{}, error) {
+	n, err := decodeJWKInt(j.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := decodeJWKInt(j.E)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+
+	public := rsa.PublicKey{N: n, E: int(e.Int64())}
+
+	if j.D == "" {
+		return &public, nil
+	}
+
+	ints := make([]*big.Int, 3)
+	for i, s := range []string{j.D, j.P, j.Q} {
+		if ints[i], err = decodeJWKInt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	private := &rsa.PrivateKey{PublicKey: public, D: ints[0], Primes: []*big.Int{ints[1], ints[2]}}
+
+	if err = private.Validate(); err != nil {
+		return nil, err
+	}
+
+	private.Precompute()
+
+	return private, nil
+}
+
+func jwkCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported curve %s", crv)
+}
+
+func (j *jwk) ecKey() (interface{}, error) {
+	curve, err := jwkCurve(j.Crv)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := decodeJWKInt(j.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := decodeJWKInt(j.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point not on curve")
+	}
+
+	public := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+
+	if j.D == "" {
+		return &public, nil
+	}
+
+	d, err := decodeJWKInt(j.D)
+	if err != nil {
+		return nil, err
+	}
+
+	if dx, dy := curve.ScalarBaseMult(d.Bytes()); dx.Cmp(x) != 0 || dy.Cmp(y) != 0 {
+		return nil, errors.New("private key doesn't match the public key")
+	}
+
+	return &ecdsa.PrivateKey{PublicKey: public, D: d}, nil
+}
+
+func (j *jwk) okpKey() (interface{}, error) {
+	if j.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %s", j.Crv)
+	}
+
+	x, err := decodeJWKField(j.X)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key size")
+	}
+
+	if j.D == "" {
+		return ed25519.PublicKey(x), nil
+	}
+
+	d, err := decodeJWKField(j.D)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d) != ed25519.SeedSize {
+		return nil, errors.New("invalid private key size")
+	}
+
+	return ed25519.NewKeyFromSeed(d), nil
+}
+
+// Public returns the key with the public key of a private key.
+func (k *JwtKey) Public() *JwtKey {
+	public := *k
+
+	if signer, ok := k.Key.(crypto.Signer); ok {
+		public.Key = signer.Public()
+	}
+
+	return &public
+}
+
+// MarshalJSON exports the public key as a JWK, the private parts of private
+// keys are never exported. Secrets of HMAC algorithms can't be exported.
+func (k *JwtKey) MarshalJSON() ([]byte, error) {
+	j, err := k.Public().jwk()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(j)
+}
+
+func (k *JwtKey) jwk() (*jwk, error) {
+	j := &jwk{Kid: k.ID, Alg: string(k.Algorithm), Use: k.Use}
+	enc := base64.RawURLEncoding
+
+	switch key := k.Key.(type) {
+	case *rsa.PublicKey:
+		j.Kty = "RSA"
+		j.N = enc.EncodeToString(key.N.Bytes())
+		j.E = enc.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		j.Kty = "EC"
+		j.Crv = key.Curve.Params().Name
+		j.X = enc.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		j.Y = enc.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		j.Kty = "OKP"
+		j.Crv = "Ed25519"
+		j.X = enc.EncodeToString(key)
+	default:
+		return nil, fmt.Errorf("%w: can not export %T", JwtErrInvalidJWK, k.Key)
+	}
+
+	return j, nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the public key,
+// base64url encoded, which is commonly used as "kid".
+func (k *JwtKey) Thumbprint() (string, error) {
+	j, err := k.Public().jwk()
+	if err != nil {
+		return "", err
+	}
+
+	// the required members, in lexicographic order
+	var members string
+	switch j.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, j.E, j.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, j.Crv, j.X, j.Y)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, j.Crv, j.X)
+	}
+
+	sum := sha256.Sum256([]byte(members))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -8,30 +8,53 @@ import (
 )
 
 type XPJwtImpl struct {
+	Mode JwtMode //token 格式，零值为兼容旧版本的 JwtModeLegacy
+}
 
+// NewJwt 创建 RFC 7519 格式的 XPJwtImpl，新代码应使用它
+func NewJwt() *XPJwtImpl {
+	return &XPJwtImpl{Mode: JwtModeRFC7519}
 }
 
 type JwtSignOption struct {
-	SignType   JwtAlgorithm   //签名算法
-	Expiration time.Duration  //过期时间
-	Audience   string         //接收方
-	Issuer     string         //签发者
-	Subject    string         //所面向的用户
-	Header     JwtHeader      //自定义的头，将被合并至 Token 的头部
+	SignType   JwtAlgorithm  //签名算法
+	Expiration time.Duration //过期时间
+	NotBefore  time.Duration //生效时间，相对签发时间，仅 RFC 7519 格式
+	Audience   string        //接收方
+	Audiences  []string      //多个接收方，aud 为数组，仅 RFC 7519 格式
+	Issuer     string        //签发者
+	Subject    string        //所面向的用户
+	ID         string        //Token 的唯一标识 jti，可由 NewTokenID 生成，用于吊销
+	Header     JwtHeader     //自定义的头，将被合并至 Token 的头部
 }
 
 type JwtVerifyOption struct {
 	SignType         JwtAlgorithm   //签名算法
 	IngoreExpiration bool           //是否忽略到期时间
-	Audience         string         //接收方
+	Audience         string         //接收方，RFC 7519 格式时 aud 可以是数组
 	Issuer           string         //签发方
 	Subject          string         //所面向的用户
 	Timeout          time.Duration  //检查到期时间时指定的时间容忍值
+	Leeway           time.Duration  //允许的时钟偏差，检查 exp 和 nbf 时放宽的时间
+	KeyResolver      JwtKeyResolver //根据 header (kid) 选择验证的密钥，如 JwtKeySet.Resolve，设置时忽略 secret
+	Validators       []JwtValidator //自定义的验证，如 RequireScopes，在签名和保留声明验证通过后依次执行
+	Denylist         JwtDenylist    //已吊销 Token 的 jti 列表，设置时拒绝没有 jti 的 Token
+
+	refresh bool //JwtRefreshRotator 验证刷新 Token，只接受 typ 为 refresh+jwt 的 Token，其他验证拒绝刷新 Token
+}
+
+func (jwt *XPJwtImpl) encoding() *base64.Encoding {
+	if jwt.Mode == JwtModeRFC7519 {
+		return base64.RawURLEncoding
+	}
+
+	return base64.StdEncoding
 }
 
 // 根据 payload 和 secret(私钥) 生成 JSON Web Token
 // 当使用 HMAC 算法时，secret 为 string 或 []byte
 // 当使用 RSA  算法时, secret 为 rsa.PrivateKey
+// 当使用 ECDSA 算法时, secret 为 ecdsa.PrivateKey，EdDSA 时为 ed25519.PrivateKey
 // 如果 opt 为 nil，则默认使用 HS256 算法
 func (jwt *XPJwtImpl) Sign(payload JwtPayload, secret interface{}, opt *JwtSignOption) (token []byte, err error) {
 	if payload == nil {
@@ -46,25 +69,32 @@ func (jwt *XPJwtImpl) Sign(payload JwtPayload, secret interface{}, opt *JwtSignO
 		return nil, JwtErrEmptySecretOrPrivateKey
 	}
 
-	var headerJSON, payloadJSON, signature []byte
+	if key, ok := secret.(*JwtKey); ok {
+		opt = key.signOption(opt)
+		secret = key.Key
+	}
 
-	if headerJSON, err = marshalHeader(opt); err != nil {
-		return
+	if opt.SignType == "" {
+		opt.SignType = JwtHS256
 	}
 
-	hBase64 := []byte(base64.StdEncoding.EncodeToString(headerJSON))
+	var headerJSON, payloadJSON, signature []byte
 
-	if payloadJSON, err = marshalPayload(payload, opt); err != nil {
+	if headerJSON, err = jwt.marshalHeader(opt); err != nil {
 		return
 	}
 
-	pBase64 := []byte(base64.StdEncoding.EncodeToString(payloadJSON))
+	enc := jwt.encoding()
 
-	if opt.SignType == "" {
-		opt.SignType = JwtHS256
+	hBase64 := []byte(enc.EncodeToString(headerJSON))
+
+	if payloadJSON, err = jwt.marshalPayload(payload, opt); err != nil {
+		return
 	}
 
-	algImp, ok := algImpMap[opt.SignType]
+	pBase64 := []byte(enc.EncodeToString(payloadJSON))
+
+	algImp, ok := algorithm(opt.SignType)
 
 	if !ok {
 		return nil, JwtErrInvalidAlgorithm
@@ -75,16 +105,27 @@ func (jwt *XPJwtImpl) Sign(payload JwtPayload, secret interface{}, opt *JwtSignO
 		return
 	}
 
-	sigBase64 := []byte(base64.StdEncoding.EncodeToString(signature))
+	sigBase64 := []byte(enc.EncodeToString(signature))
 
 	return bytes.Join([][]byte{hBase64, pBase64, sigBase64}, periodBytes), nil
 }
 
 // 验证 token 并返回 header 和 payload
 // 当使用 HMAC 算法时，secret 为 string 或 []byte
-// 当使用 RSA  算法时, secret 为 rsa.PrivateKey
+// 当使用 RSA  算法时, secret 为 rsa.PublicKey 或 rsa.PrivateKey
+// 当使用 ECDSA 算法时, secret 为 ecdsa.PublicKey，EdDSA 时为 ed25519.PublicKey
 // 如果 opt 为 nil，则默认使用 HS256 算法
+// RFC 7519 格式时 header 的 alg 必须与 SignType 一致，并检查 nbf
 func (jwt *XPJwtImpl) Verify(token []byte, secret interface{}, opt *JwtVerifyOption) (header JwtHeader, payload JwtPayload, err error) {
+	s, err := jwt.verify(token, secret, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.header, s.payload, nil
+}
+
+func (jwt *XPJwtImpl) verify(token []byte, secret interface{}, opt *JwtVerifyOption) (s segments, err error) {
 	var (
 		ok bool
 		ai algorithmImplementation
@@ -95,43 +136,131 @@ func (jwt *XPJwtImpl) Verify(token []byte, secret interface{}, opt *JwtVerifyOpt
 		opt.IngoreExpiration = true
 	}
 
-	if opt.SignType == "" {
-		opt.SignType = JwtHS256
+	standard := jwt.Mode == JwtModeRFC7519
+
+	// 旧版本对所有解码和签名错误都返回 JwtErrInvalidSignature
+	if s, err = decode(token, jwt.encoding()); err != nil {
+		if standard && err == JwtErrInvalidToken {
+			return s, err
+		}
+		return s, JwtErrInvalidSignature
+	}
+
+	signType := opt.SignType
+	// 旧版本不检查 header 的 alg，密钥由 header 选择时必须检查
+	checkAlg := standard
+
+	if opt.KeyResolver != nil {
+		checkAlg = true
+		if secret, err = opt.KeyResolver(s.header); err != nil {
+			return s, err
+		}
+	}
+
+	// 未指定 SignType 时使用密钥的算法，密钥没有算法时 header 的 alg 必须与密钥类型相符
+	if key, isKey := secret.(*JwtKey); isKey {
+		checkAlg = true
+		if signType == "" {
+			alg, _ := s.header["alg"].(string)
+			if !key.accepts(JwtAlgorithm(alg)) {
+				return s, JwtErrInvalidAlgorithm
+			}
+			signType = JwtAlgorithm(alg)
+		}
+		secret = key.Key
 	}
 
-	if ai, ok = algImpMap[opt.SignType]; !ok {
-		return nil, nil, JwtErrInvalidAlgorithm
+	if signType == "" {
+		signType = JwtHS256
 	}
 
-	if header, payload, err = ai.verify(token, secret); err != nil {
-		return nil, nil, JwtErrInvalidSignature
+	if ai, ok = algorithm(signType); !ok {
+		return s, JwtErrInvalidAlgorithm
 	}
 
+	if checkAlg && s.header["alg"] != string(signType) {
+		return s, JwtErrInvalidAlgorithm
+	}
+
+	if err = ai.verify(s.signingInput, s.signature, secret); err != nil {
+		if standard && err == JwtErrInvalidKeyType {
+			return s, err
+		}
+		return s, JwtErrInvalidSignature
+	}
+
+	header, payload := s.header, s.payload
+
+	if standard {
+		err = jwt.verifyStandard(header, payload, opt)
+	} else {
+		err = verifyLegacy(header, payload, opt)
+	}
+
+	if err != nil {
+		return s, err
+	}
+
+	if opt.Denylist != nil {
+		if err = payload.checkDenylist(opt.Denylist); err != nil {
+			return s, err
+		}
+	}
+
+	return s, runValidators(header, payload, opt.Validators)
+}
+
+func verifyLegacy(header JwtHeader, payload JwtPayload, opt *JwtVerifyOption) error {
 	if !header.hasValidType() {
-		return nil, nil, JwtErrInvalidHeaderType
+		return JwtErrInvalidHeaderType
 	}
 
 	if !payload.checkStringClaim("aud", opt.Audience) ||
 		!payload.checkStringClaim("iss", opt.Issuer) ||
 		!payload.checkStringClaim("sub", opt.Subject) {
-		return nil, nil, JwtErrInvalidReservedClaim
+		return JwtErrInvalidReservedClaim
 	}
 
 	if !opt.IngoreExpiration {
-		if ok := payload.checkExpiration(opt.Timeout); !ok {
-			return nil, nil, JwtErrTokenExpired
+		if ok := payload.checkExpiration(opt.Timeout - opt.Leeway); !ok {
+			return JwtErrTokenExpired
 		}
 	}
 
-	return
+	return nil
+}
+
+func (jwt *XPJwtImpl) verifyStandard(header JwtHeader, payload JwtPayload, opt *JwtVerifyOption) error {
+	if !header.hasStandardType(opt.refresh) {
+		return JwtErrInvalidHeaderType
+	}
+
+	if !payload.hasAudience(opt.Audience) {
+		return &JwtClaimError{Claim: "aud", Err: JwtErrInvalidReservedClaim}
+	}
+
+	if !payload.checkStringClaim("iss", opt.Issuer) {
+		return &JwtClaimError{Claim: "iss", Err: JwtErrInvalidReservedClaim}
+	}
+
+	if !payload.checkStringClaim("sub", opt.Subject) {
+		return &JwtClaimError{Claim: "sub", Err: JwtErrInvalidReservedClaim}
+	}
+
+	return payload.checkTimes(opt)
 }
 
-func marshalHeader(opt *JwtSignOption) ([]byte, error) {
+func (jwt *XPJwtImpl) marshalHeader(opt *JwtSignOption) ([]byte, error) {
 	h := map[string]interface{}{
 		"alg": opt.SignType,
 		"typ": "JWT",
 	}
 
+	// RFC 7519 格式时 Header 可以指定 typ，如 RFC 9068 的 at+jwt，旧格式的 Verify 只接受 JWT
+	if typ, ok := opt.Header["typ"].(string); ok && typ != "" && jwt.Mode == JwtModeRFC7519 {
+		h["typ"] = typ
+	}
+
 	if opt.Header != nil {
 		if err := Map(&h, opt.Header); err != nil {
 			return nil, err
@@ -141,19 +270,36 @@ func marshalHeader(opt *JwtSignOption) ([]byte, error) {
 	return json.Marshal(h)
 }
 
-func marshalPayload(payload JwtPayload, opt *JwtSignOption) ([]byte, error) {
-	claims := JwtPayload{"iat": time.Now().Unix()}
+func (jwt *XPJwtImpl) marshalPayload(payload JwtPayload, opt *JwtSignOption) ([]byte, error) {
+	now := time.Now()
+	claims := JwtPayload{"iat": now.Unix()}
 
 	if opt.Issuer != "" {
 		claims["iss"] = opt.Issuer
 	}
 	if opt.Expiration != 0 {
-		claims["exp"] = opt.Expiration / 1e9
+		if jwt.Mode == JwtModeRFC7519 {
+			claims["exp"] = now.Add(opt.Expiration).Unix()
+		} else {
+			claims["exp"] = opt.Expiration / 1e9
+		}
+	}
+	if opt.NotBefore != 0 && jwt.Mode == JwtModeRFC7519 {
+		claims["nbf"] = now.Add(opt.NotBefore).Unix()
 	}
 	if opt.Subject != "" {
 		claims["sub"] = opt.Subject
 	}
-	if opt.Audience != "" {
+	if opt.ID != "" {
+		claims["jti"] = opt.ID
+	}
+	if len(opt.Audiences) > 0 && jwt.Mode == JwtModeRFC7519 {
+		aud := opt.Audiences
+		if opt.Audience != "" {
+			aud = append([]string{opt.Audience}, aud...)
+		}
+		claims["aud"] = aud
+	} else if opt.Audience != "" {
 		claims["aud"] = opt.Audience
 	}
 
@@ -162,4 +308,24 @@ func marshalPayload(payload JwtPayload, opt *JwtSignOption) ([]byte, error) {
 	}
 
 	return json.Marshal(claims)
-}
\ No newline at end of file
+}
+
+func (k *JwtKey) signOption(opt *JwtSignOption) *JwtSignOption {
+	o := *opt
+
+	if o.SignType == "" {
+		o.SignType = k.algorithm()
+	}
+
+	if k.ID != "" {
+		if _, ok := o.Header["kid"]; !ok {
+			header := JwtHeader{"kid": k.ID}
+			for name, v := range o.Header {
+				header[name] = v
+			}
+			o.Header = header
+		}
+	}
+
+	return &o
+}
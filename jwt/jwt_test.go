@@ -0,0 +1,35 @@
+package jwt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSignHeaderType(t *testing.T) {
+	key := []byte("secret")
+	for _, c := range []struct {
+		jwt  *XPJwtImpl
+		want string
+	}{
+		{&XPJwtImpl{}, "JWT"},
+		{NewJwt(), "at+jwt"},
+	} {
+		token, err := c.jwt.Sign(JwtPayload{"sub": "alice"}, key, &JwtSignOption{
+			SignType:   JwtHS256,
+			Expiration: time.Minute,
+			Header:     JwtHeader{"typ": "at+jwt", "alg": "none", "kid": "k1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, _, err := c.jwt.Verify(token, key, &JwtVerifyOption{SignType: JwtHS256})
+		if err != nil {
+			t.Fatalf("mode %v: Verify: %v", c.jwt.Mode, err)
+		}
+		data, _ := json.Marshal(header)
+		if header["typ"] != c.want || header["alg"] != string(JwtHS256) || header["kid"] != "k1" {
+			t.Errorf("mode %v: header %s, want typ %s, alg HS256 and kid k1", c.jwt.Mode, data, c.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JwtKeyResolver returns the key verifying a token from its header, usually
+// by its "kid". It returns a key accepted by Verify or a *JwtKey.
+type JwtKeyResolver func(header JwtHeader) (interface{}, error)
+
+// JwtKeySet is a JSON Web Key Set (RFC 7517), json.Marshal exports its public keys.
+type JwtKeySet struct {
+	Keys []*JwtKey
+}
+
+// ParseJWKS parses a JSON Web Key Set. Keys of unsupported types, e.g. X25519,
+// are skipped.
+func ParseJWKS(data []byte) (*JwtKeySet, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	s := &JwtKeySet{}
+	var lastErr error
+
+	for _, raw := range set.Keys {
+		k, err := ParseJWK(raw)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		s.Keys = append(s.Keys, k)
+	}
+
+	if len(s.Keys) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return s, nil
+}
+
+// Add adds keys to the set.
+func (s *JwtKeySet) Add(keys ...*JwtKey) *JwtKeySet {
+	s.Keys = append(s.Keys, keys...)
+	return s
+}
+
+// Lookup returns the key of a kid.
+func (s *JwtKeySet) Lookup(kid string) (*JwtKey, bool) {
+	for _, k := range s.Keys {
+		if k.ID == kid {
+			return k, true
+		}
+	}
+
+	return nil, false
+}
+
+// Resolve is the JwtKeyResolver of the set: the key of the "kid" of the
+// header, or the only signing key matching "alg" for tokens without "kid".
+func (s *JwtKeySet) Resolve(header JwtHeader) (interface{}, error) {
+	if kid, ok := header["kid"].(string); ok {
+		if k, ok := s.Lookup(kid); ok && k.Use != "enc" {
+			return k, nil
+		}
+		return nil, fmt.Errorf("%w: kid %s", JwtErrKeyNotFound, kid)
+	}
+
+	alg, _ := header["alg"].(string)
+	var found *JwtKey
+
+	for _, k := range s.Keys {
+		if k.Use == "enc" || !k.accepts(JwtAlgorithm(alg)) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: token without kid and several %s keys", JwtErrKeyNotFound, alg)
+		}
+		found = k
+	}
+
+	if found == nil {
+		return nil, JwtErrKeyNotFound
+	}
+
+	return found, nil
+}
+
+// MarshalJSON exports the public keys of the set, the HMAC secrets are left out.
+func (s *JwtKeySet) MarshalJSON() ([]byte, error) {
+	keys := []*jwk{}
+
+	for _, k := range s.Keys {
+		switch k.Key.(type) {
+		case []byte, string:
+			continue
+		}
+		j, err := k.Public().jwk()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, j)
+	}
+
+	return json.Marshal(map[string]interface{}{"keys": keys})
+}
+
+// accepts reports whether the key can verify tokens of alg: alg is the one
+// of the key, or of the family of the key type when the key has none.
+func (k *JwtKey) accepts(alg JwtAlgorithm) bool {
+	if k.Algorithm != "" {
+		return k.Algorithm == alg
+	}
+
+	a := string(alg)
+
+	switch key := k.Key.(type) {
+	case []byte, string:
+		return strings.HasPrefix(a, "HS")
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return strings.HasPrefix(a, "RS") || strings.HasPrefix(a, "PS")
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(key) == alg
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(&key.PublicKey) == alg
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return alg == JwtEdDSA
+	}
+
+	// keys of algorithms added with RegisterAlgorithm need an Algorithm
+	return false
+}
+
+// algorithm is the algorithm of the key, the default one of its type if it has none
+func (k *JwtKey) algorithm() JwtAlgorithm {
+	if k.Algorithm != "" {
+		return k.Algorithm
+	}
+
+	switch key := k.Key.(type) {
+	case []byte, string:
+		return JwtHS256
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return JwtRS256
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(key)
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(&key.PublicKey)
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return JwtEdDSA
+	}
+
+	return ""
+}
+
+func ecdsaAlgorithm(key *ecdsa.PublicKey) JwtAlgorithm {
+	switch key.Curve.Params().Name {
+	case "P-256":
+		return JwtES256
+	case "P-384":
+		return JwtES384
+	case "P-521":
+		return JwtES512
+	}
+
+	return ""
+}
+
+// JwtRemoteKeySet is a JWKS fetched from a URL, e.g. the jwks_uri of an
+// identity provider, and cached for TTL. A token with an unknown "kid" fetches
+// the set again, at most once per MinRefresh, so that rotated keys are found.
+// When a fetch fails the keys fetched before are still used, and the set is
+// fetched again after MinRefresh.
+type JwtRemoteKeySet struct {
+	URL        string
+	TTL        time.Duration
+	MinRefresh time.Duration
+
+	get     func(url string) ([]byte, error)
+	mu      sync.Mutex
+	set     *JwtKeySet
+	fetched time.Time
+	failed  time.Time
+}
+
+// NewRemoteKeySet creates a remote key set, get fetches the body of the URL,
+// http.Get with a 10s timeout if nil. stl.JwtRemoteKeySet uses stl's HTTP client.
+func NewRemoteKeySet(url string, ttl time.Duration, get func(url string) ([]byte, error)) *JwtRemoteKeySet {
+	if get == nil {
+		get = httpGet
+	}
+
+	return &JwtRemoteKeySet{URL: url, TTL: ttl, MinRefresh: time.Minute, get: get}
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func httpGet(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: GET %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// KeySet returns the cached set, fetched again once TTL expired.
+func (r *JwtRemoteKeySet) KeySet() (*JwtKeySet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.set != nil && (time.Since(r.fetched) < r.TTL || time.Since(r.failed) < r.MinRefresh) {
+		return r.set, nil
+	}
+
+	return r.fetch()
+}
+
+// Refresh fetches the set, unless it was fetched less than MinRefresh ago.
+func (r *JwtRemoteKeySet) Refresh() (*JwtKeySet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.set != nil && (time.Since(r.fetched) < r.MinRefresh || time.Since(r.failed) < r.MinRefresh) {
+		return r.set, nil
+	}
+
+	return r.fetch()
+}
+
+func (r *JwtRemoteKeySet) fetch() (*JwtKeySet, error) {
+	data, err := r.get(r.URL)
+
+	var set *JwtKeySet
+	if err == nil {
+		set, err = ParseJWKS(data)
+	}
+
+	if err != nil {
+		r.failed = time.Now()
+		if r.set != nil {
+			return r.set, nil
+		}
+		return nil, err
+	}
+
+	r.set, r.fetched = set, time.Now()
+
+	return set, nil
+}
+
+// Resolve is the JwtKeyResolver of the remote set.
+func (r *JwtRemoteKeySet) Resolve(header JwtHeader) (interface{}, error) {
+	set, err := r.KeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := set.Resolve(header)
+	if err == nil {
+		return key, nil
+	}
+
+	if set, err = r.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return set.Resolve(header)
+}
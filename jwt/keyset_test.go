@@ -0,0 +1,197 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestECKey(t *testing.T, kid string) *JwtKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &JwtKey{ID: kid, Key: key}
+}
+
+// jwksServer serves the public keys of set, failing with 500 while down
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	set     *JwtKeySet
+	down    bool
+	fetches int64
+}
+
+func newJWKSServer(t *testing.T, keys ...*JwtKey) *jwksServer {
+	s := &jwksServer{set: (&JwtKeySet{}).Add(keys...)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.fetches, 1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.down {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(s.set); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) update(f func()) {
+	s.mu.Lock()
+	f()
+	s.mu.Unlock()
+}
+
+func signTestToken(t *testing.T, key *JwtKey) []byte {
+	t.Helper()
+	token, err := NewJwt().Sign(JwtPayload{"sub": "alice"}, key, &JwtSignOption{Expiration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func verifyRemote(remote *JwtRemoteKeySet, token []byte) error {
+	_, _, err := NewJwt().Verify(token, nil, &JwtVerifyOption{SignType: JwtES256, KeyResolver: remote.Resolve})
+	return err
+}
+
+func TestRemoteKeySetRotation(t *testing.T) {
+	k1, k2 := newTestECKey(t, "k1"), newTestECKey(t, "k2")
+	server := newJWKSServer(t, k1)
+	remote := NewRemoteKeySet(server.URL, time.Hour, nil)
+	remote.MinRefresh = 0
+
+	if err := verifyRemote(remote, signTestToken(t, k1)); err != nil {
+		t.Fatalf("verify with k1: %v", err)
+	}
+	if err := verifyRemote(remote, signTestToken(t, k1)); err != nil {
+		t.Fatalf("verify with the cached k1: %v", err)
+	}
+	if n := atomic.LoadInt64(&server.fetches); n != 1 {
+		t.Fatalf("fetched %d times, want the set cached after the first fetch", n)
+	}
+
+	// the identity provider rotates to k2, the unknown kid refreshes the set
+	server.update(func() { server.set = (&JwtKeySet{}).Add(k2) })
+	if err := verifyRemote(remote, signTestToken(t, k2)); err != nil {
+		t.Fatalf("verify with the rotated k2: %v", err)
+	}
+	if n := atomic.LoadInt64(&server.fetches); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+	if err := verifyRemote(remote, signTestToken(t, k1)); !errors.Is(err, JwtErrKeyNotFound) {
+		t.Errorf("verify with the retired k1 = %v, want JwtErrKeyNotFound", err)
+	}
+}
+
+func TestRemoteKeySetMinRefresh(t *testing.T) {
+	k1 := newTestECKey(t, "k1")
+	server := newJWKSServer(t, k1)
+	remote := NewRemoteKeySet(server.URL, time.Hour, nil)
+
+	unknown := signTestToken(t, newTestECKey(t, "unknown"))
+	for i := 0; i < 5; i++ {
+		if err := verifyRemote(remote, unknown); !errors.Is(err, JwtErrKeyNotFound) {
+			t.Fatalf("verify with an unknown kid = %v, want JwtErrKeyNotFound", err)
+		}
+	}
+	if n := atomic.LoadInt64(&server.fetches); n != 1 {
+		t.Errorf("fetched %d times, want unknown kids to refresh at most once per MinRefresh", n)
+	}
+}
+
+func TestRemoteKeySetFetchFailure(t *testing.T) {
+	k1 := newTestECKey(t, "k1")
+	server := newJWKSServer(t, k1)
+	remote := NewRemoteKeySet(server.URL, time.Millisecond, nil)
+	remote.MinRefresh = 0
+
+	if err := verifyRemote(remote, signTestToken(t, k1)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	server.update(func() { server.down = true })
+	time.Sleep(5 * time.Millisecond)
+	if err := verifyRemote(remote, signTestToken(t, k1)); err != nil {
+		t.Fatalf("verify while the server is down: %v, want the keys fetched before", err)
+	}
+
+	down := NewRemoteKeySet(server.URL, time.Hour, nil)
+	if err := verifyRemote(down, signTestToken(t, k1)); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("verify without keys fetched before = %v, want the fetch error", err)
+	}
+}
+
+func TestKeySetMarshalSkipsSecrets(t *testing.T) {
+	ec := newTestECKey(t, "ec")
+	set := (&JwtKeySet{}).Add(
+		&JwtKey{ID: "bytes", Key: []byte("secret")},
+		&JwtKey{ID: "string", Key: "secret"},
+		ec,
+	)
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), `"d"`) {
+		t.Errorf("exported %s, want only the public keys", data)
+	}
+
+	parsed, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Keys) != 1 || parsed.Keys[0].ID != "ec" {
+		t.Fatalf("exported %s, want the public key of ec only", data)
+	}
+	if _, ok := parsed.Keys[0].Key.(*ecdsa.PublicKey); !ok {
+		t.Errorf("exported key is a %T, want *ecdsa.PublicKey", parsed.Keys[0].Key)
+	}
+}
+
+func TestParseJWKMismatchedPrivateKey(t *testing.T) {
+	a, b := newTestECKey(t, "a"), newTestECKey(t, "b")
+
+	ja, err := a.Public().jwk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+
+	ja.D = enc.EncodeToString(a.Key.(*ecdsa.PrivateKey).D.Bytes())
+	if _, err = ParseJWK(mustMarshal(t, ja)); err != nil {
+		t.Fatalf("ParseJWK of a private key: %v", err)
+	}
+
+	ja.D = enc.EncodeToString(b.Key.(*ecdsa.PrivateKey).D.Bytes())
+	if _, err = ParseJWK(mustMarshal(t, ja)); !errors.Is(err, JwtErrInvalidJWK) {
+		t.Errorf("ParseJWK with the d of another key = %v, want JwtErrInvalidJWK", err)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xpsuper/stl/memorycache"
+)
+
+var (
+	// ErrRefreshTokenReused is returned when a refresh token is used again after
+	// its rotation, which revokes its whole family.
+	JwtErrRefreshTokenReused = errors.New("jwt: refresh token reused")
+	// ErrRefreshLegacyMode is returned by a JwtRefreshRotator whose Jwt is in
+	// JwtModeLegacy, which only accepts the "typ" JWT.
+	JwtErrRefreshLegacyMode = errors.New("jwt: refresh tokens need JwtModeRFC7519")
+)
+
+// refreshTokenType is the "typ" of refresh tokens, so that access tokens
+// signed with the same key can't be used to refresh, and refresh tokens are
+// rejected by the Verify of access tokens
+const refreshTokenType = "refresh+jwt"
+
+// NewTokenID returns a random "jti" of 128 bits, base64url encoded.
+func NewTokenID() string {
+	id := make([]byte, 16)
+
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// JwtRefreshStore keeps the current refresh token of each family, the chain
+// of refresh tokens rotated from a login. Shared implementations must make
+// Rotate atomic, e.g. with a compare-and-set in Redis.
+type JwtRefreshStore interface {
+	// Start starts a family whose current token is jti, kept for ttl
+	Start(family, jti string, ttl time.Duration) error
+	// Rotate replaces jti by next and keeps the family for ttl. It returns
+	// JwtErrRefreshTokenReused when jti isn't the current token of the family,
+	// and JwtErrTokenRevoked when the family was revoked or expired.
+	Rotate(family, jti, next string, ttl time.Duration) error
+	// RevokeFamily revokes the current token of the family
+	RevokeFamily(family string) error
+}
+
+// JwtMemoryRefreshStore is a JwtRefreshStore of one process on a memorycache.Cache.
+type JwtMemoryRefreshStore struct {
+	mu    sync.Mutex
+	cache *memorycache.Cache
+}
+
+// NewMemoryRefreshStore creates a memory refresh store on cache, or on a cache
+// of a million families if nil. Evicted families are revoked.
+func NewMemoryRefreshStore(cache *memorycache.Cache) *JwtMemoryRefreshStore {
+	if cache == nil {
+		cache = memorycache.NewCache(memorycache.Configure().MaxSize(1 << 20))
+	}
+
+	return &JwtMemoryRefreshStore{cache: cache}
+}
+
+func (s *JwtMemoryRefreshStore) Start(family, jti string, ttl time.Duration) error {
+	s.cache.Set(refreshKey(family), jti, ttl)
+	return nil
+}
+
+func (s *JwtMemoryRefreshStore) Rotate(family, jti, next string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := refreshKey(family)
+	item := s.cache.Get(key)
+
+	if item == nil || item.Expired() {
+		return JwtErrTokenRevoked
+	}
+
+	if item.Value() != jti {
+		return JwtErrRefreshTokenReused
+	}
+
+	s.cache.Set(key, next, ttl)
+
+	return nil
+}
+
+func (s *JwtMemoryRefreshStore) RevokeFamily(family string) error {
+	s.cache.Delete(refreshKey(family))
+	return nil
+}
+
+func refreshKey(family string) string {
+	return "jwt:refresh:" + family
+}
+
+// JwtRefreshRotator issues refresh tokens which are used once: each refresh
+// returns a new token of the same family and the previous one is rejected.
+// When a rotated token is used again, by an attacker who stole it or by the
+// user after the attacker refreshed, the family is revoked and both must log in.
+// Concurrent refreshes with the same token, e.g. from two tabs, are a reuse.
+type JwtRefreshRotator struct {
+	Jwt       *XPJwtImpl
+	Store     JwtRefreshStore
+	SignKey   interface{}
+	VerifyKey interface{}
+	SignType  JwtAlgorithm
+	// TTL is the lifetime of each refresh token, a family lives while it is refreshed
+	TTL    time.Duration
+	Issuer string
+	Leeway time.Duration
+}
+
+// NewRefreshRotator creates a rotator of RFC 7519 tokens, with a memory store if store is nil.
+// The rotator returns JwtErrRefreshLegacyMode if Jwt is replaced by one in JwtModeLegacy.
+func NewRefreshRotator(store JwtRefreshStore, signKey, verifyKey interface{}, signType JwtAlgorithm, ttl time.Duration) *JwtRefreshRotator {
+	if store == nil {
+		store = NewMemoryRefreshStore(nil)
+	}
+
+	return &JwtRefreshRotator{
+		Jwt:       NewJwt(),
+		Store:     store,
+		SignKey:   signKey,
+		VerifyKey: verifyKey,
+		SignType:  signType,
+		TTL:       ttl,
+	}
+}
+
+// Issue starts a family, at login, and returns its first refresh token.
+// claims, e.g. the scopes granted, are carried by the tokens of the family.
+func (r *JwtRefreshRotator) Issue(subject string, claims JwtPayload) ([]byte, error) {
+	if r.Jwt.Mode != JwtModeRFC7519 {
+		return nil, JwtErrRefreshLegacyMode
+	}
+
+	family, jti := NewTokenID(), NewTokenID()
+
+	token, err := r.sign(subject, family, jti, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.Store.Start(family, jti, r.TTL); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Rotate verifies a refresh token and returns the next token of its family
+// with the verified payload, from which the caller issues the access token.
+func (r *JwtRefreshRotator) Rotate(token []byte) (next []byte, payload JwtPayload, err error) {
+	_, payload, err = r.verify(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	family, _ := payload["fam"].(string)
+	jti, _ := payload["jti"].(string)
+	subject, _ := payload["sub"].(string)
+
+	if family == "" || jti == "" {
+		return nil, nil, &JwtClaimError{Claim: "fam", Err: JwtErrMissingClaim}
+	}
+
+	nextID := NewTokenID()
+
+	if next, err = r.sign(subject, family, nextID, payload); err != nil {
+		return nil, nil, err
+	}
+
+	if err = r.Store.Rotate(family, jti, nextID, r.TTL); err != nil {
+		if err == JwtErrRefreshTokenReused {
+			if revokeErr := r.Store.RevokeFamily(family); revokeErr != nil {
+				return nil, nil, revokeErr
+			}
+		}
+		return nil, nil, err
+	}
+
+	return next, payload, nil
+}
+
+// Revoke revokes the family of a refresh token, at logout.
+func (r *JwtRefreshRotator) Revoke(token []byte) error {
+	_, payload, err := r.verify(token)
+	if err != nil {
+		return err
+	}
+
+	family, _ := payload["fam"].(string)
+	if family == "" {
+		return &JwtClaimError{Claim: "fam", Err: JwtErrMissingClaim}
+	}
+
+	return r.Store.RevokeFamily(family)
+}
+
+func (r *JwtRefreshRotator) sign(subject, family, jti string, claims JwtPayload) ([]byte, error) {
+	payload := JwtPayload{}
+
+	for name, v := range claims {
+		switch name {
+		case "iss", "sub", "aud", "exp", "nbf", "iat", "jti", "fam":
+		default:
+			payload[name] = v
+		}
+	}
+
+	payload["fam"] = family
+
+	return r.Jwt.Sign(payload, r.SignKey, &JwtSignOption{
+		SignType:   r.SignType,
+		Expiration: r.TTL,
+		Issuer:     r.Issuer,
+		Subject:    subject,
+		ID:         jti,
+		Header:     JwtHeader{"typ": refreshTokenType},
+	})
+}
+
+func (r *JwtRefreshRotator) verify(token []byte) (JwtHeader, JwtPayload, error) {
+	if r.Jwt.Mode != JwtModeRFC7519 {
+		return nil, nil, JwtErrRefreshLegacyMode
+	}
+
+	return r.Jwt.Verify(token, r.VerifyKey, &JwtVerifyOption{
+		SignType: r.SignType,
+		Issuer:   r.Issuer,
+		Leeway:   r.Leeway,
+		refresh:  true,
+	})
+}
@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenType(t *testing.T) {
+	key := []byte("secret")
+	rotator := NewRefreshRotator(nil, key, key, JwtHS256, time.Hour)
+
+	refresh, err := rotator.Issue("alice", JwtPayload{"scope": "orders:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = NewJwt().Verify(refresh, key, &JwtVerifyOption{SignType: JwtHS256}); !errors.Is(err, JwtErrInvalidHeaderType) {
+		t.Errorf("Verify of a refresh token = %v, want JwtErrInvalidHeaderType", err)
+	}
+
+	for _, typ := range []string{"", "JWT", "at+jwt"} {
+		opt := &JwtSignOption{SignType: JwtHS256, Expiration: time.Hour, Subject: "alice", ID: NewTokenID()}
+		if typ != "" {
+			opt.Header = JwtHeader{"typ": typ}
+		}
+		access, err := NewJwt().Sign(JwtPayload{"fam": "f"}, key, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = NewJwt().Verify(access, key, &JwtVerifyOption{SignType: JwtHS256}); err != nil {
+			t.Errorf("Verify of a %q token: %v", typ, err)
+		}
+		if _, _, err = rotator.Rotate(access); !errors.Is(err, JwtErrInvalidHeaderType) {
+			t.Errorf("Rotate of a %q token = %v, want JwtErrInvalidHeaderType", typ, err)
+		}
+	}
+
+	next, payload, err := rotator.Rotate(refresh)
+	if err != nil || payload["scope"] != "orders:read" {
+		t.Fatalf("Rotate = %v, %v", payload, err)
+	}
+	if _, _, err = rotator.Rotate(refresh); !errors.Is(err, JwtErrRefreshTokenReused) {
+		t.Errorf("Rotate of a rotated token = %v, want JwtErrRefreshTokenReused", err)
+	}
+	if _, _, err = rotator.Rotate(next); !errors.Is(err, JwtErrTokenRevoked) {
+		t.Errorf("Rotate after a reuse = %v, want JwtErrTokenRevoked", err)
+	}
+}
+
+func TestRefreshLegacyMode(t *testing.T) {
+	key := []byte("secret")
+	rotator := NewRefreshRotator(nil, key, key, JwtHS256, time.Hour)
+	refresh, err := rotator.Issue("alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotator.Jwt = &XPJwtImpl{Mode: JwtModeLegacy}
+	if _, err = rotator.Issue("alice", nil); !errors.Is(err, JwtErrRefreshLegacyMode) {
+		t.Errorf("Issue = %v, want JwtErrRefreshLegacyMode", err)
+	}
+	if _, _, err = rotator.Rotate(refresh); !errors.Is(err, JwtErrRefreshLegacyMode) {
+		t.Errorf("Rotate = %v, want JwtErrRefreshLegacyMode", err)
+	}
+}
+
+func TestRevokeWithoutExpiration(t *testing.T) {
+	key := []byte("secret")
+	deny := NewMemoryDenylist(nil)
+	opt := &JwtVerifyOption{SignType: JwtHS256, IngoreExpiration: true, Denylist: deny}
+
+	token, err := NewJwt().Sign(JwtPayload{"sub": "alice"}, key, &JwtSignOption{SignType: JwtHS256, ID: NewTokenID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, payload, err := NewJwt().Verify(token, key, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload["exp"]; ok {
+		t.Fatalf("payload %v, want a token without exp", payload)
+	}
+
+	if err = NewJwt().Revoke(deny, payload); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, _, err = NewJwt().Verify(token, key, opt); !errors.Is(err, JwtErrTokenRevoked) {
+		t.Errorf("Verify of a revoked token = %v, want JwtErrTokenRevoked", err)
+	}
+}
@@ -1,11 +1,9 @@
 package jwt
 
 import (
-	"bytes"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"encoding/base64"
 )
 
 func init() {
@@ -32,26 +30,26 @@ func (ra rsaAlgImp) sign(content []byte, privateKey interface{}) ([]byte, error)
 	return rsa.SignPKCS1v15(rand.Reader, key, ra.hash, h.Sum(nil))
 }
 
-func (ra rsaAlgImp) verify(token []byte, privateKey interface{}) (header JwtHeader, payload JwtPayload, err error) {
-	if header, payload, err = decode(token); err != nil {
-		return
+// verify 接受公钥，兼容以前传入私钥的用法
+func (ra rsaAlgImp) verify(content, signature []byte, key interface{}) error {
+	var publicKey *rsa.PublicKey
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		publicKey = k
+	case *rsa.PrivateKey:
+		publicKey = &k.PublicKey
+	default:
+		return JwtErrInvalidKeyType
 	}
 
-	signatureReceive, err := base64.StdEncoding.DecodeString(string(bytes.Split(token, periodBytes)[2]))
-
-	if err != nil {
-		return
-	}
-
-	signatureExpect, err := ra.sign(token[0:bytes.LastIndexByte(token, '.')], privateKey)
+	h := ra.hash.New()
 
-	if err != nil {
-		return
-	}
+	h.Write(content)
 
-	if !bytes.Equal(signatureReceive, signatureExpect) {
-		return nil, nil, JwtErrInvalidSignature
+	if rsa.VerifyPKCS1v15(publicKey, ra.hash, h.Sum(nil), signature) != nil {
+		return JwtErrInvalidSignature
 	}
 
-	return
+	return nil
 }
@@ -0,0 +1,59 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+)
+
+func init() {
+	algImpMap[JwtPS256] = rsaPSSAlgImp{hash: crypto.SHA256}
+	algImpMap[JwtPS384] = rsaPSSAlgImp{hash: crypto.SHA384}
+	algImpMap[JwtPS512] = rsaPSSAlgImp{hash: crypto.SHA512}
+}
+
+// rsaPSSAlgImp 签名时 salt 长度等于 hash 长度，验证时接受任意 salt 长度
+type rsaPSSAlgImp struct {
+	hash crypto.Hash
+}
+
+func (ra rsaPSSAlgImp) sign(content []byte, privateKey interface{}) ([]byte, error) {
+	key, ok := privateKey.(*rsa.PrivateKey)
+
+	if !ok {
+		return nil, JwtErrInvalidKeyType
+	}
+
+	h := ra.hash.New()
+
+	h.Write(content)
+
+	return rsa.SignPSS(rand.Reader, key, ra.hash, h.Sum(nil), &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	})
+}
+
+func (ra rsaPSSAlgImp) verify(content, signature []byte, key interface{}) error {
+	var publicKey *rsa.PublicKey
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		publicKey = k
+	case *rsa.PrivateKey:
+		publicKey = &k.PublicKey
+	default:
+		return JwtErrInvalidKeyType
+	}
+
+	h := ra.hash.New()
+
+	h.Write(content)
+
+	if rsa.VerifyPSS(publicKey, ra.hash, h.Sum(nil), signature, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthAuto,
+	}) != nil {
+		return JwtErrInvalidSignature
+	}
+
+	return nil
+}
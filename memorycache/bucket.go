@@ -8,6 +8,7 @@ import (
 type bucket struct {
 	sync.RWMutex
 	lookup map[string]*Item
+	stats  counters
 }
 
 func (b *bucket) itemCount() int {
@@ -22,9 +23,10 @@ func (b *bucket) get(key string) *Item {
 	return b.lookup[key]
 }
 
-func (b *bucket) set(key string, value interface{}, duration time.Duration) (*Item, *Item) {
+func (b *bucket) set(key string, value interface{}, duration time.Duration, tags ...string) (*Item, *Item) {
 	expires := time.Now().Add(duration).UnixNano()
 	item := newItem(key, value, expires)
+	item.tags = tags
 	b.Lock()
 	defer b.Unlock()
 	existing := b.lookup[key]
@@ -40,6 +42,17 @@ func (b *bucket) delete(key string) *Item {
 	return item
 }
 
+// deleteItem deletes the key only if it still holds the item
+func (b *bucket) deleteItem(item *Item) bool {
+	b.Lock()
+	defer b.Unlock()
+	if b.lookup[item.key] != item {
+		return false
+	}
+	delete(b.lookup, item.key)
+	return true
+}
+
 func (b *bucket) clear() {
 	b.Lock()
 	defer b.Unlock()
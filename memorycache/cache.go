@@ -2,31 +2,44 @@
 package memorycache
 
 import (
-	"container/list"
 	"hash/fnv"
+	"os"
 	"sync/atomic"
 	"time"
 )
 
 type Cache struct {
 	*Configuration
-	list        *list.List
+	policy      Policy
 	size        int64
+	evictions   int64
+	promotions  int64
 	buckets     []*bucket
 	bucketMask  uint32
+	loader      *loader
+	tags        *tagIndex
 	deletables  chan *Item
 	promotables chan *Item
 	donec       chan struct{}
+
+	snapshotStop chan struct{}
+	snapshotDone chan struct{}
 }
 
 // Create a new cache with the specified configuration
 // See ccache.Configure() for creating a configuration
 func NewCache(config *Configuration) *Cache {
+	newPolicy := config.policyFactory
+	if newPolicy == nil {
+		newPolicy = NewLRU
+	}
 	c := &Cache{
-		list:          list.New(),
+		policy:        newPolicy(config.maxSize),
 		Configuration: config,
 		bucketMask:    uint32(config.buckets) - 1,
 		buckets:       make([]*bucket, config.buckets),
+		loader:        newLoader(),
+		tags:          newTagIndex(),
 	}
 	for i := 0; i < int(config.buckets); i++ {
 		c.buckets[i] = &bucket{
@@ -34,6 +47,16 @@ func NewCache(config *Configuration) *Cache {
 		}
 	}
 	c.restart()
+	if config.snapshotPath != "" {
+		if err := c.RestoreFile(config.snapshotPath); err != nil && !os.IsNotExist(err) {
+			c.snapshotError(err)
+		}
+		if config.snapshotInterval > 0 {
+			c.snapshotStop = make(chan struct{})
+			c.snapshotDone = make(chan struct{})
+			go c.snapshotWorker()
+		}
+	}
 	return c
 }
 
@@ -50,7 +73,9 @@ func (c *Cache) ItemCount() int {
 // is expired and item.TTL() to see how long until the item expires (which
 // will be negative for an already expired item).
 func (c *Cache) Get(key string) *Item {
-	item := c.bucket(key).get(key)
+	bucket := c.bucket(key)
+	item := bucket.get(key)
+	bucket.stats.hit(item)
 	if item == nil {
 		return nil
 	}
@@ -78,35 +103,62 @@ func (c *Cache) Set(key string, value interface{}, duration time.Duration) {
 
 // Replace the value if it exists, does not set if it doesn't.
 // Returns true if the item existed an was replaced, false otherwise.
-// Replace does not reset item's TTL
+// Replace does not reset item's TTL nor its tags
 func (c *Cache) Replace(key string, value interface{}) bool {
 	item := c.bucket(key).get(key)
 	if item == nil {
 		return false
 	}
-	c.Set(key, value, item.TTL())
+	c.set(key, value, item.TTL(), item.tags...)
 	return true
 }
 
 // Attempts to get the value from the cache and calles fetch on a miss (missing
 // or stale item). If fetch returns an error, no value is cached and the error
 // is returned back to the caller.
+// Concurrent Fetches of the same key share a single call to fetch.
+// With StaleWhileRevalidate configured, an item expired for less than the
+// window is returned while fetch refreshes it in the background.
+// With NegativeTTL configured, the error of fetch is returned to further
+// Fetches of the key until the ttl elapses.
 func (c *Cache) Fetch(key string, duration time.Duration, fetch func() (interface{}, error)) (*Item, error) {
 	item := c.Get(key)
 	if item != nil && !item.Expired() {
 		return item, nil
 	}
-	value, err := fetch()
-	if err != nil {
-		return nil, err
+	var tags []string
+	if item != nil {
+		// a refreshed item keeps its tags
+		tags = item.tags
+	}
+	load := func() (*Item, error) {
+		value, err := fetch()
+		if err != nil {
+			if c.negativeTTL > 0 {
+				c.loader.remember(key, err, c.negativeTTL)
+			}
+			return nil, err
+		}
+		return c.set(key, value, duration, tags...), nil
+	}
+	if item != nil && c.staleWhileRevalidate > 0 && -item.TTL() < c.staleWhileRevalidate {
+		c.loader.doAsync(key, load)
+		return item, nil
+	}
+	if c.negativeTTL > 0 {
+		if err := c.loader.failed(key); err != nil {
+			return nil, err
+		}
 	}
-	return c.set(key, value, duration), nil
+	return c.loader.do(key, load)
 }
 
 // Remove the item from the cache, return true if the item was present, false otherwise.
 func (c *Cache) Delete(key string) bool {
-	item := c.bucket(key).delete(key)
+	bucket := c.bucket(key)
+	item := bucket.delete(key)
 	if item != nil {
+		bucket.stats.delete(1)
 		c.deletables <- item
 		return true
 	}
@@ -118,13 +170,19 @@ func (c *Cache) Clear() {
 	for _, bucket := range c.buckets {
 		bucket.clear()
 	}
-	c.size = 0
-	c.list = list.New()
+	c.loader.clear()
+	c.tags.clear()
+	atomic.StoreInt64(&c.size, 0)
+	c.policy.Clear()
 }
 
 // Stops the background worker. Operations performed on the cache after Stop
 // is called are likely to panic
 func (c *Cache) Stop() {
+	if c.snapshotStop != nil {
+		close(c.snapshotStop)
+		<-c.snapshotDone
+	}
 	close(c.promotables)
 	<-c.donec
 }
@@ -141,8 +199,16 @@ func (c *Cache) deleteItem(bucket *bucket, item *Item) {
 	c.deletables <- item
 }
 
-func (c *Cache) set(key string, value interface{}, duration time.Duration) *Item {
-	item, existing := c.bucket(key).set(key, value, duration)
+func (c *Cache) set(key string, value interface{}, duration time.Duration, tags ...string) *Item {
+	if c.negativeTTL > 0 {
+		c.loader.forget(key)
+	}
+	bucket := c.bucket(key)
+	item, existing := bucket.set(key, value, duration, tags...)
+	bucket.stats.set()
+	if len(tags) > 0 {
+		c.tags.add(item)
+	}
 	if existing != nil {
 		c.deletables <- existing
 	}
@@ -190,14 +256,18 @@ drain:
 }
 
 func (c *Cache) doDelete(item *Item) {
-	if item.element == nil {
+	if len(item.tags) > 0 {
+		c.tags.remove(item)
+	}
+	if !item.added {
 		item.promotions = -2
 	} else {
-		c.size -= item.size
+		atomic.AddInt64(&c.size, -item.size)
 		if c.onDelete != nil {
 			c.onDelete(item)
 		}
-		c.list.Remove(item.element)
+		c.policy.Remove(item)
+		item.added = false
 	}
 }
 
@@ -206,36 +276,37 @@ func (c *Cache) doPromote(item *Item) bool {
 	if item.promotions == -2 {
 		return false
 	}
-	if item.element != nil { //not a new item
+	if item.added { //not a new item
 		if item.shouldPromote(c.getsPerPromote) {
-			c.list.MoveToFront(item.element)
+			c.policy.Promote(item)
 			item.promotions = 0
+			atomic.AddInt64(&c.promotions, 1)
 		}
 		return false
 	}
 
-	c.size += item.size
-	item.element = c.list.PushFront(item)
+	atomic.AddInt64(&c.size, item.size)
+	c.policy.Add(item)
+	item.added = true
 	return true
 }
 
 func (c *Cache) gc() {
-	element := c.list.Back()
-	for i := 0; i < c.itemsToPrune; i++ {
-		if element == nil {
-			return
+	c.policy.Evict(c.itemsToPrune, func(item *Item) bool {
+		if c.tracking && atomic.LoadInt32(&item.refCount) != 0 {
+			return false
 		}
-		prev := element.Prev()
-		item := element.Value.(*Item)
-		if c.tracking == false || atomic.LoadInt32(&item.refCount) == 0 {
-			c.bucket(item.key).delete(item.key)
-			c.size -= item.size
-			c.list.Remove(element)
-			if c.onDelete != nil {
-				c.onDelete(item)
-			}
-			item.promotions = -2
+		c.bucket(item.key).delete(item.key)
+		if len(item.tags) > 0 {
+			c.tags.remove(item)
 		}
-		element = prev
-	}
+		atomic.AddInt64(&c.size, -item.size)
+		atomic.AddInt64(&c.evictions, 1)
+		if c.onDelete != nil {
+			c.onDelete(item)
+		}
+		item.promotions = -2
+		item.added = false
+		return true
+	})
 }
@@ -1,5 +1,7 @@
 package memorycache
 
+import "time"
+
 type Configuration struct {
 	maxSize        int64
 	buckets        int
@@ -9,6 +11,15 @@ type Configuration struct {
 	getsPerPromote int32
 	tracking       bool
 	onDelete       func(item *Item)
+
+	staleWhileRevalidate time.Duration
+	negativeTTL          time.Duration
+
+	policyFactory PolicyFactory
+
+	snapshotPath     string
+	snapshotInterval time.Duration
+	onSnapshotError  func(err error)
 }
 
 // Creates a configuration object with sensible defaults
@@ -101,3 +112,46 @@ func (c *Configuration) OnDelete(callback func(item *Item)) *Configuration {
 	c.onDelete = callback
 	return c
 }
+
+// Policy sets the eviction policy of the cache, NewLRU or NewTinyLFU.
+// Only supported by Cache, LayeredCache is always LRU.
+// [NewLRU]
+func (c *Configuration) Policy(factory PolicyFactory) *Configuration {
+	c.policyFactory = factory
+	return c
+}
+
+// StaleWhileRevalidate lets Fetch return an item which expired less than window
+// ago, instead of waiting for the fetch function. The item is refreshed in the
+// background by a single call to the fetch function.
+// [0]
+func (c *Configuration) StaleWhileRevalidate(window time.Duration) *Configuration {
+	c.staleWhileRevalidate = window
+	return c
+}
+
+// NegativeTTL caches the error returned by the fetch function of Fetch for ttl.
+// Meanwhile Fetch of the same key returns that error without calling fetch again,
+// unless the key is Set. This protects the backend from retry storms of failing keys.
+// [0]
+func (c *Configuration) NegativeTTL(ttl time.Duration) *Configuration {
+	c.negativeTTL = ttl
+	return c
+}
+
+// PeriodicSnapshot makes the cache restore its items from path when created, then
+// snapshot them to path every interval and once more on Stop. This allows warm
+// restarts. Values of custom types need a codec, see RegisterCodec.
+// Only supported by Cache, LayeredCache ignores it.
+func (c *Configuration) PeriodicSnapshot(path string, interval time.Duration) *Configuration {
+	c.snapshotPath = path
+	c.snapshotInterval = interval
+	return c
+}
+
+// OnSnapshotError allows setting a callback function to be notified of the errors
+// of the snapshots configured by PeriodicSnapshot, which happen in the background.
+func (c *Configuration) OnSnapshotError(callback func(err error)) *Configuration {
+	c.onSnapshotError = callback
+	return c
+}
@@ -50,6 +50,9 @@ type Item struct {
 	size       int64
 	value      interface{}
 	element    *list.Element
+	segment    uint8
+	added      bool
+	tags       []string
 }
 
 func newItem(key string, value interface{}, expires int64) *Item {
@@ -75,6 +78,11 @@ func (i *Item) Value() interface{} {
 	return i.value
 }
 
+// Tags returns the tags the item was set with.
+func (i *Item) Tags() []string {
+	return i.tags
+}
+
 func (i *Item) track() {
 	atomic.AddInt32(&i.refCount, 1)
 }
@@ -8,6 +8,7 @@ import (
 type layeredBucket struct {
 	sync.RWMutex
 	buckets map[string]*bucket
+	stats   counters
 }
 
 func (b *layeredBucket) itemCount() int {
@@ -72,13 +73,15 @@ func (b *layeredBucket) deleteAll(primary string, deletables chan *Item) bool {
 	bucket.Lock()
 	defer bucket.Unlock()
 
-	if l := len(bucket.lookup); l == 0 {
+	l := len(bucket.lookup)
+	if l == 0 {
 		return false
 	}
 	for key, item := range bucket.lookup {
 		delete(bucket.lookup, key)
 		deletables <- item
 	}
+	b.stats.delete(int64(l))
 	return true
 }
 
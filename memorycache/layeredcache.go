@@ -14,6 +14,8 @@ type LayeredCache struct {
 	buckets     []*layeredBucket
 	bucketMask  uint32
 	size        int64
+	evictions   int64
+	promotions  int64
 	deletables  chan *Item
 	promotables chan *Item
 	donec       chan struct{}
@@ -62,7 +64,9 @@ func (c *LayeredCache) ItemCount() int {
 // is expired and item.TTL() to see how long until the item expires (which
 // will be negative for an already expired item).
 func (c *LayeredCache) Get(primary, secondary string) *Item {
-	item := c.bucket(primary).get(primary, secondary)
+	bucket := c.bucket(primary)
+	item := bucket.get(primary, secondary)
+	bucket.stats.hit(item)
 	if item == nil {
 		return nil
 	}
@@ -87,6 +91,7 @@ func (c *LayeredCache) GetOrCreateSecondaryCache(primary string) *SecondaryCache
 	return &SecondaryCache{
 		bucket: bkt,
 		pCache: c,
+		stats:  &primaryBkt.stats,
 	}
 }
 
@@ -135,8 +140,10 @@ func (c *LayeredCache) Fetch(primary, secondary string, duration time.Duration,
 
 // Remove the item from the cache, return true if the item was present, false otherwise.
 func (c *LayeredCache) Delete(primary, secondary string) bool {
-	item := c.bucket(primary).delete(primary, secondary)
+	bucket := c.bucket(primary)
+	item := bucket.delete(primary, secondary)
 	if item != nil {
+		bucket.stats.delete(1)
 		c.deletables <- item
 		return true
 	}
@@ -153,7 +160,7 @@ func (c *LayeredCache) Clear() {
 	for _, bucket := range c.buckets {
 		bucket.clear()
 	}
-	c.size = 0
+	atomic.StoreInt64(&c.size, 0)
 	c.list = list.New()
 }
 
@@ -169,7 +176,9 @@ func (c *LayeredCache) restart() {
 }
 
 func (c *LayeredCache) set(primary, secondary string, value interface{}, duration time.Duration) *Item {
-	item, existing := c.bucket(primary).set(primary, secondary, value, duration)
+	bucket := c.bucket(primary)
+	item, existing := bucket.set(primary, secondary, value, duration)
+	bucket.stats.set()
 	if existing != nil {
 		c.deletables <- existing
 	}
@@ -202,7 +211,7 @@ func (c *LayeredCache) worker() {
 			if item.element == nil {
 				atomic.StoreInt32(&item.promotions, -2)
 			} else {
-				c.size -= item.size
+				atomic.AddInt64(&c.size, -item.size)
 				if c.onDelete != nil {
 					c.onDelete(item)
 				}
@@ -221,10 +230,11 @@ func (c *LayeredCache) doPromote(item *Item) bool {
 		if item.shouldPromote(c.getsPerPromote) {
 			c.list.MoveToFront(item.element)
 			atomic.StoreInt32(&item.promotions, 0)
+			atomic.AddInt64(&c.promotions, 1)
 		}
 		return false
 	}
-	c.size += item.size
+	atomic.AddInt64(&c.size, item.size)
 	item.element = c.list.PushFront(item)
 	return true
 }
@@ -239,7 +249,8 @@ func (c *LayeredCache) gc() {
 		item := element.Value.(*Item)
 		if c.tracking == false || atomic.LoadInt32(&item.refCount) == 0 {
 			c.bucket(item.group).delete(item.group, item.key)
-			c.size -= item.size
+			atomic.AddInt64(&c.size, -item.size)
+			atomic.AddInt64(&c.evictions, 1)
 			c.list.Remove(element)
 			item.promotions = -2
 		}
@@ -0,0 +1,147 @@
+package memorycache
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// the number of remembered errors from which expired ones get swept
+const negativeSweepSize = 1024
+
+// a call is an in-flight or completed fetch for a key
+type call struct {
+	wg   sync.WaitGroup
+	item *Item
+	err  error
+}
+
+// PanicError is the error returned to the callers waiting for a fetch which
+// panicked. The caller which ran the fetch panics again with Value.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("memorycache: fetch panicked: %v\n\n%s", e.Value, e.Stack)
+}
+
+// negative is a fetch error remembered until expires
+type negative struct {
+	err     error
+	expires int64
+}
+
+// loader coalesces concurrent fetches of the same key into a single call
+// and remembers failed fetches when negative caching is enabled.
+type loader struct {
+	sync.Mutex
+	calls     map[string]*call
+	negatives map[string]negative
+}
+
+func newLoader() *loader {
+	return &loader{
+		calls:     make(map[string]*call),
+		negatives: make(map[string]negative),
+	}
+}
+
+// do executes fn for the key, making sure only one execution is in-flight
+// at a time. Concurrent callers wait for the running call and share its result.
+func (l *loader) do(key string, fn func() (*Item, error)) (*Item, error) {
+	l.Lock()
+	if c, ok := l.calls[key]; ok {
+		l.Unlock()
+		c.wg.Wait()
+		return c.item, c.err
+	}
+	c := l.begin(key)
+	l.Unlock()
+
+	l.run(key, c, fn)
+	if p, ok := c.err.(*PanicError); ok {
+		panic(p.Value)
+	}
+	return c.item, c.err
+}
+
+// doAsync starts fn in the background unless a call for the key is already in-flight.
+// A panic of fn is returned as a *PanicError to the waiting callers only.
+func (l *loader) doAsync(key string, fn func() (*Item, error)) {
+	l.Lock()
+	if _, ok := l.calls[key]; ok {
+		l.Unlock()
+		return
+	}
+	c := l.begin(key)
+	l.Unlock()
+
+	go l.run(key, c, fn)
+}
+
+// must be called with the lock held
+func (l *loader) begin(key string) *call {
+	c := new(call)
+	c.wg.Add(1)
+	l.calls[key] = c
+	return c
+}
+
+// run executes fn and releases the waiters, a panic of fn becomes the error of the call
+func (l *loader) run(key string, c *call, fn func() (*Item, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.item, c.err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		l.Lock()
+		delete(l.calls, key)
+		l.Unlock()
+		c.wg.Done()
+	}()
+	c.item, c.err = fn()
+}
+
+// failed returns the remembered error of the key, if it hasn't expired
+func (l *loader) failed(key string) error {
+	l.Lock()
+	defer l.Unlock()
+	n, ok := l.negatives[key]
+	if !ok {
+		return nil
+	}
+	if n.expires < time.Now().UnixNano() {
+		delete(l.negatives, key)
+		return nil
+	}
+	return n.err
+}
+
+func (l *loader) remember(key string, err error, ttl time.Duration) {
+	now := time.Now()
+	l.Lock()
+	defer l.Unlock()
+	if len(l.negatives) >= negativeSweepSize {
+		// expired errors are only dropped on lookup, sweep the ones never looked up again
+		for k, n := range l.negatives {
+			if n.expires < now.UnixNano() {
+				delete(l.negatives, k)
+			}
+		}
+	}
+	l.negatives[key] = negative{err: err, expires: now.Add(ttl).UnixNano()}
+}
+
+func (l *loader) forget(key string) {
+	l.Lock()
+	delete(l.negatives, key)
+	l.Unlock()
+}
+
+func (l *loader) clear() {
+	l.Lock()
+	l.negatives = make(map[string]negative)
+	l.Unlock()
+}
@@ -0,0 +1,64 @@
+package memorycache
+
+import "container/list"
+
+// Policy decides which items a Cache evicts when it grows over MaxSize.
+// All methods are called from the single worker goroutine of the cache,
+// implementations don't need to be safe for concurrent use.
+type Policy interface {
+	// Add a new item to the policy.
+	Add(item *Item)
+	// Promote an item after it was got GetsPerPromote times.
+	Promote(item *Item)
+	// Remove an item which was deleted or replaced.
+	Remove(item *Item)
+	// Evict offers up to n items to evict, least valuable first.
+	// evict returns false if the item can't be evicted (it is tracked), in
+	// which case the policy keeps it. Evicted items must be removed from the policy.
+	Evict(n int, evict func(item *Item) bool)
+	// Clear removes all the items.
+	Clear()
+}
+
+// PolicyFactory creates the policy of a cache configured with maxSize.
+type PolicyFactory func(maxSize int64) Policy
+
+// lru is the default policy: items are evicted from the least recently promoted.
+type lru struct {
+	list *list.List
+}
+
+// NewLRU creates a least recently used policy, which is the default policy.
+func NewLRU(maxSize int64) Policy {
+	return &lru{list: list.New()}
+}
+
+func (p *lru) Add(item *Item) {
+	item.element = p.list.PushFront(item)
+}
+
+func (p *lru) Promote(item *Item) {
+	p.list.MoveToFront(item.element)
+}
+
+func (p *lru) Remove(item *Item) {
+	p.list.Remove(item.element)
+}
+
+func (p *lru) Evict(n int, evict func(item *Item) bool) {
+	element := p.list.Back()
+	for i := 0; i < n; i++ {
+		if element == nil {
+			return
+		}
+		prev := element.Prev()
+		if evict(element.Value.(*Item)) {
+			p.list.Remove(element)
+		}
+		element = prev
+	}
+}
+
+func (p *lru) Clear() {
+	p.list = list.New()
+}
@@ -0,0 +1,120 @@
+package memorycache
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var policies = []struct {
+	name    string
+	factory PolicyFactory
+}{
+	{"LRU", NewLRU},
+	{"TinyLFU", NewTinyLFU},
+}
+
+// zipfKeys returns n keys among keySpace following a Zipf distribution of
+// parameter s, the first keys being the most frequent
+func zipfKeys(seed int64, s float64, keySpace uint64, n int) []string {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(seed)), s, 1, keySpace-1)
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.FormatUint(zipf.Uint64(), 10)
+	}
+	return keys
+}
+
+// withScans interleaves a scan of scanLen keys used once every period keys
+func withScans(keys []string, period, scanLen int) []string {
+	out := make([]string, 0, len(keys)+len(keys)/period*scanLen)
+	scan := 0
+	for i, key := range keys {
+		out = append(out, key)
+		if (i+1)%period == 0 {
+			for j := 0; j < scanLen; j++ {
+				out = append(out, "scan:"+strconv.Itoa(scan))
+				scan++
+			}
+		}
+	}
+	return out
+}
+
+// hitRatio replays keys on the policy as a cache of maxSize items whose
+// every Get is promoted, evicting one item at a time
+func hitRatio(factory PolicyFactory, maxSize int64, keys []string) float64 {
+	policy := factory(maxSize)
+	items := make(map[string]*Item, maxSize)
+	evict := func(item *Item) bool {
+		delete(items, item.key)
+		return true
+	}
+	hits := 0
+	for _, key := range keys {
+		if item, ok := items[key]; ok {
+			hits++
+			policy.Promote(item)
+			continue
+		}
+		item := newItem(key, nil, 0)
+		item.added = true
+		items[key] = item
+		policy.Add(item)
+		for int64(len(items)) > maxSize {
+			policy.Evict(1, evict)
+		}
+	}
+	return float64(hits) / float64(len(keys))
+}
+
+func BenchmarkHitRatioZipf(b *testing.B) {
+	keys := zipfKeys(1, 1.1, 100000, 500000)
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				ratio = hitRatio(p.factory, 1000, keys)
+			}
+			b.ReportMetric(ratio*100, "hit%")
+		})
+	}
+}
+
+func BenchmarkHitRatioZipfWithScans(b *testing.B) {
+	keys := withScans(zipfKeys(1, 1.1, 100000, 500000), 10000, 2000)
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				ratio = hitRatio(p.factory, 1000, keys)
+			}
+			b.ReportMetric(ratio*100, "hit%")
+		})
+	}
+}
+
+func BenchmarkCacheZipf(b *testing.B) {
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			cache := NewCache(Configure().MaxSize(10000).GetsPerPromote(1).Policy(p.factory))
+			defer cache.Stop()
+			keys := zipfKeys(1, 1.1, 1000000, 1<<16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					key := keys[i&(len(keys)-1)]
+					if cache.Get(key) == nil {
+						cache.Set(key, key, time.Minute)
+					}
+					i++
+				}
+			})
+			b.StopTimer()
+			stats := cache.Stats()
+			b.ReportMetric(stats.HitRate()*100, "hit%")
+		})
+	}
+}
@@ -5,18 +5,22 @@ import "time"
 type SecondaryCache struct {
 	bucket *bucket
 	pCache *LayeredCache
+	stats  *counters
 }
 
 // Get the secondary key.
 // The semantics are the same as for LayeredCache.Get
 func (s *SecondaryCache) Get(secondary string) *Item {
-	return s.bucket.get(secondary)
+	item := s.bucket.get(secondary)
+	s.stats.hit(item)
+	return item
 }
 
 // Set the secondary key to a value.
 // The semantics are the same as for LayeredCache.Set
 func (s *SecondaryCache) Set(secondary string, value interface{}, duration time.Duration) *Item {
 	item, existing := s.bucket.set(secondary, value, duration)
+	s.stats.set()
 	if existing != nil {
 		s.pCache.deletables <- existing
 	}
@@ -43,6 +47,7 @@ func (s *SecondaryCache) Fetch(secondary string, duration time.Duration, fetch f
 func (s *SecondaryCache) Delete(secondary string) bool {
 	item := s.bucket.delete(secondary)
 	if item != nil {
+		s.stats.delete(1)
 		s.pCache.deletables <- item
 		return true
 	}
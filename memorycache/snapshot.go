@@ -0,0 +1,263 @@
+package memorycache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// ErrSnapshotVersion is returned by Restore for snapshots written by an unknown version.
+var ErrSnapshotVersion = errors.New("memorycache: unsupported snapshot version")
+
+// Codec converts cached values to bytes and back when snapshotting a cache.
+type Codec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+type registeredCodec struct {
+	name  string
+	codec Codec
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byType map[reflect.Type]registeredCodec
+	byName map[string]Codec
+}{
+	byType: make(map[reflect.Type]registeredCodec),
+	byName: make(map[string]Codec),
+}
+
+// RegisterCodec sets the codec used to snapshot values of the same type as sample.
+// Values of types without codec are encoded with encoding/gob, which requires
+// gob.Register for non builtin types.
+func RegisterCodec(sample interface{}, codec Codec) {
+	t := reflect.TypeOf(sample)
+	name := codecName(t)
+	codecs.Lock()
+	codecs.byType[t] = registeredCodec{name: name, codec: codec}
+	codecs.byName[name] = codec
+	codecs.Unlock()
+}
+
+// codecName is the name of the codec of t in snapshots, the import path
+// qualifies named types so that models.User of two packages don't collide
+func codecName(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		return t.PkgPath() + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + codecName(t.Elem())
+	case reflect.Slice:
+		return "[]" + codecName(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), codecName(t.Elem()))
+	case reflect.Map:
+		return "map[" + codecName(t.Key()) + "]" + codecName(t.Elem())
+	}
+	return t.String()
+}
+
+// RegisterJSON registers a codec snapshotting values of the same type as sample with encoding/json.
+func RegisterJSON(sample interface{}) {
+	RegisterCodec(sample, jsonCodec{typ: reflect.TypeOf(sample)})
+}
+
+func lookupCodec(value interface{}) (string, Codec) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	if c, ok := codecs.byType[reflect.TypeOf(value)]; ok {
+		return c.name, c.codec
+	}
+	return "", gobCodec{}
+}
+
+func codecByName(name string) (Codec, error) {
+	if name == "" {
+		return gobCodec{}, nil
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+	if c, ok := codecs.byName[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("memorycache: no codec registered for %s", name)
+}
+
+type jsonCodec struct {
+	typ reflect.Type
+}
+
+func (c jsonCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (c jsonCodec) Unmarshal(data []byte) (interface{}, error) {
+	v := reflect.New(c.typ)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	// encode through a pointer to interface so that the concrete type is sent
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+type snapshotHeader struct {
+	Version int
+}
+
+type snapshotEntry struct {
+	Key   string
+	TTL   time.Duration
+	Codec string
+	Value []byte
+	Tags  []string
+}
+
+// Snapshot writes the items which are not expired, with their remaining TTL, to w.
+// Items can be loaded back into a cache with Restore.
+func (c *Cache) Snapshot(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+	for _, b := range c.buckets {
+		b.RLock()
+		items := make([]*Item, 0, len(b.lookup))
+		for _, item := range b.lookup {
+			items = append(items, item)
+		}
+		b.RUnlock()
+
+		for _, item := range items {
+			ttl := item.TTL()
+			if ttl <= 0 {
+				continue
+			}
+			name, codec := lookupCodec(item.value)
+			data, err := codec.Marshal(item.value)
+			if err != nil {
+				return fmt.Errorf("memorycache: snapshot %s: %s", item.key, err)
+			}
+			err = enc.Encode(snapshotEntry{Key: item.key, TTL: ttl, Codec: name, Value: data, Tags: item.tags})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Restore sets the items written by Snapshot into the cache.
+// Items are restored with the TTL they had when the snapshot was taken.
+func (c *Cache) Restore(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Version != snapshotVersion {
+		return ErrSnapshotVersion
+	}
+	for {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		codec, err := codecByName(entry.Codec)
+		if err != nil {
+			return err
+		}
+		value, err := codec.Unmarshal(entry.Value)
+		if err != nil {
+			return fmt.Errorf("memorycache: restore %s: %s", entry.Key, err)
+		}
+		c.set(entry.Key, value, entry.TTL, entry.Tags...)
+	}
+}
+
+// SnapshotFile writes a snapshot to path, through a temporary file so a crash
+// never leaves a partial snapshot behind.
+func (c *Cache) SnapshotFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err = c.Snapshot(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RestoreFile loads a snapshot written by SnapshotFile.
+func (c *Cache) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Restore(f)
+}
+
+// snapshots to the configured file periodically, and a last time on Stop
+func (c *Cache) snapshotWorker() {
+	defer close(c.snapshotDone)
+	ticker := time.NewTicker(c.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.snapshotError(c.SnapshotFile(c.snapshotPath))
+		case <-c.snapshotStop:
+			c.snapshotError(c.SnapshotFile(c.snapshotPath))
+			return
+		}
+	}
+}
+
+func (c *Cache) snapshotError(err error) {
+	if err != nil && c.onSnapshotError != nil {
+		c.onSnapshotError(err)
+	}
+}
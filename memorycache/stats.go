@@ -0,0 +1,147 @@
+package memorycache
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// counters of a bucket, updated atomically.
+// Keeping them per bucket spreads the writes of concurrent Gets.
+type counters struct {
+	hits    int64
+	misses  int64
+	sets    int64
+	deletes int64
+}
+
+func (c *counters) hit(item *Item) {
+	if item == nil || item.Expired() {
+		atomic.AddInt64(&c.misses, 1)
+	} else {
+		atomic.AddInt64(&c.hits, 1)
+	}
+}
+
+func (c *counters) set() {
+	atomic.AddInt64(&c.sets, 1)
+}
+
+func (c *counters) delete(n int64) {
+	atomic.AddInt64(&c.deletes, n)
+}
+
+func (c *counters) addTo(s *Stats) {
+	s.Hits += atomic.LoadInt64(&c.hits)
+	s.Misses += atomic.LoadInt64(&c.misses)
+	s.Sets += atomic.LoadInt64(&c.sets)
+	s.Deletes += atomic.LoadInt64(&c.deletes)
+}
+
+// Stats is a snapshot of the counters of a cache.
+// Counters are cumulative since the cache was created.
+type Stats struct {
+	// Gets which found a not expired item
+	Hits int64
+	// Gets which found nothing or an expired item
+	Misses int64
+	// Values set, including those set by Fetch and Replace
+	Sets int64
+	// Items removed by Delete or DeleteAll
+	Deletes int64
+	// Items pruned by the garbage collection when MaxSize was reached
+	Evictions int64
+	// Items moved to the front of the LRU list
+	Promotions int64
+	// Current size, the sum of item sizes, and the configured MaxSize
+	Size    int64
+	MaxSize int64
+	// Number of items, in total and per bucket
+	Items   int
+	Buckets []int
+}
+
+// HitRate returns Hits / (Hits + Misses), 0 if nothing was got yet.
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// labelEscaper escapes a label value as the text exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the stats in the Prometheus text exposition format.
+// Every metric is labelled with cache="name" so several caches can be exposed
+// by the same handler.
+func (s Stats) WritePrometheus(w io.Writer, name string) error {
+	label := `cache="` + labelEscaper.Replace(name) + `"`
+	metrics := []struct {
+		name, typ, help string
+		value           int64
+	}{
+		{"memorycache_hits_total", "counter", "Gets which found a not expired item.", s.Hits},
+		{"memorycache_misses_total", "counter", "Gets which found nothing or an expired item.", s.Misses},
+		{"memorycache_sets_total", "counter", "Values set.", s.Sets},
+		{"memorycache_deletes_total", "counter", "Items deleted.", s.Deletes},
+		{"memorycache_evictions_total", "counter", "Items pruned when the cache was full.", s.Evictions},
+		{"memorycache_promotions_total", "counter", "Items promoted in the LRU list.", s.Promotions},
+		{"memorycache_size", "gauge", "Sum of the size of items.", s.Size},
+		{"memorycache_max_size", "gauge", "Configured max size.", s.MaxSize},
+		{"memorycache_items", "gauge", "Number of items.", int64(s.Items)},
+	}
+	for _, m := range metrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s{%s} %d\n", m.name, m.help, m.name, m.typ, m.name, label, m.value)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "# HELP memorycache_bucket_items Number of items per bucket.\n# TYPE memorycache_bucket_items gauge\n")
+	if err != nil {
+		return err
+	}
+	for i, n := range s.Buckets {
+		_, err = fmt.Fprintf(w, "memorycache_bucket_items{%s,bucket=\"%d\"} %d\n", label, i, n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the counters of the cache.
+func (c *Cache) Stats() Stats {
+	s := Stats{
+		Evictions:  atomic.LoadInt64(&c.evictions),
+		Promotions: atomic.LoadInt64(&c.promotions),
+		Size:       atomic.LoadInt64(&c.size),
+		MaxSize:    c.maxSize,
+		Buckets:    make([]int, len(c.buckets)),
+	}
+	for i, b := range c.buckets {
+		b.stats.addTo(&s)
+		s.Buckets[i] = b.itemCount()
+		s.Items += s.Buckets[i]
+	}
+	return s
+}
+
+// Stats returns a snapshot of the counters of the cache.
+func (c *LayeredCache) Stats() Stats {
+	s := Stats{
+		Evictions:  atomic.LoadInt64(&c.evictions),
+		Promotions: atomic.LoadInt64(&c.promotions),
+		Size:       atomic.LoadInt64(&c.size),
+		MaxSize:    c.maxSize,
+		Buckets:    make([]int, len(c.buckets)),
+	}
+	for i, b := range c.buckets {
+		b.stats.addTo(&s)
+		s.Buckets[i] = b.itemCount()
+		s.Items += s.Buckets[i]
+	}
+	return s
+}
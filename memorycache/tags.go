@@ -0,0 +1,83 @@
+package memorycache
+
+import (
+	"sync"
+	"time"
+)
+
+// tagIndex maps tags to the items set with them.
+// Items rather than keys are indexed, so that the removal of a replaced item
+// doesn't unregister the item which replaced it.
+type tagIndex struct {
+	sync.Mutex
+	lookup map[string]map[*Item]struct{}
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{lookup: make(map[string]map[*Item]struct{})}
+}
+
+func (t *tagIndex) add(item *Item) {
+	t.Lock()
+	defer t.Unlock()
+	for _, tag := range item.tags {
+		items, ok := t.lookup[tag]
+		if !ok {
+			items = make(map[*Item]struct{})
+			t.lookup[tag] = items
+		}
+		items[item] = struct{}{}
+	}
+}
+
+func (t *tagIndex) remove(item *Item) {
+	t.Lock()
+	defer t.Unlock()
+	for _, tag := range item.tags {
+		items, ok := t.lookup[tag]
+		if !ok {
+			continue
+		}
+		delete(items, item)
+		if len(items) == 0 {
+			delete(t.lookup, tag)
+		}
+	}
+}
+
+func (t *tagIndex) items(tag string) []*Item {
+	t.Lock()
+	defer t.Unlock()
+	items := make([]*Item, 0, len(t.lookup[tag]))
+	for item := range t.lookup[tag] {
+		items = append(items, item)
+	}
+	return items
+}
+
+func (t *tagIndex) clear() {
+	t.Lock()
+	t.lookup = make(map[string]map[*Item]struct{})
+	t.Unlock()
+}
+
+// SetWithTags sets the value in the cache for the specified duration, tagged
+// with tags. All the items sharing a tag can be deleted with InvalidateTag.
+func (c *Cache) SetWithTags(key string, value interface{}, duration time.Duration, tags ...string) {
+	c.set(key, value, duration, tags...)
+}
+
+// InvalidateTag deletes all the items set with the tag.
+// Returns the number of items deleted.
+func (c *Cache) InvalidateTag(tag string) int {
+	count := 0
+	for _, item := range c.tags.items(tag) {
+		bucket := c.bucket(item.key)
+		if bucket.deleteItem(item) {
+			bucket.stats.delete(1)
+			c.deletables <- item
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,267 @@
+package memorycache
+
+import "container/list"
+
+const (
+	segmentWindow uint8 = iota
+	segmentProbation
+	segmentProtected
+)
+
+// tinyLFU is the W-TinyLFU policy: new items enter a small LRU window, when
+// the window is full its oldest item is only admitted into the main space
+// if it was accessed more often than the item the main space would evict.
+// Frequencies are estimated by a count-min sketch, so scans of items used
+// once don't flush the frequently used ones.
+// The main space is a segmented LRU: probation for items admitted and
+// protected for the items promoted while in probation.
+type tinyLFU struct {
+	sketch    *cmSketch
+	window    *list.List
+	probation *list.List
+	protected *list.List
+
+	windowSize    int64
+	mainSize      int64
+	protectedSize int64
+
+	windowMax    int64
+	mainMax      int64
+	protectedMax int64
+}
+
+// NewTinyLFU creates a W-TinyLFU policy, the window takes 1% of maxSize and the
+// protected segment 80% of the rest.
+// Frequencies are counted on Set and on promotions, a GetsPerPromote of 1 gives
+// the most accurate estimation.
+func NewTinyLFU(maxSize int64) Policy {
+	windowMax := maxSize / 100
+	if windowMax < 1 {
+		windowMax = 1
+	}
+	mainMax := maxSize - windowMax
+	return &tinyLFU{
+		sketch:       newCMSketch(maxSize),
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		windowMax:    windowMax,
+		mainMax:      mainMax,
+		protectedMax: mainMax * 8 / 10,
+	}
+}
+
+func (p *tinyLFU) Add(item *Item) {
+	p.sketch.increment(item.key)
+	item.segment = segmentWindow
+	item.element = p.window.PushFront(item)
+	p.windowSize += item.size
+
+	// move the overflow of the window to the main space while it has room,
+	// once full the overflow has to win against a main item on eviction.
+	for p.windowSize > p.windowMax {
+		back := p.window.Back()
+		candidate := back.Value.(*Item)
+		if candidate == item || p.mainSize+candidate.size > p.mainMax {
+			return
+		}
+		p.window.Remove(back)
+		p.windowSize -= candidate.size
+		p.pushProbation(candidate)
+	}
+}
+
+func (p *tinyLFU) Promote(item *Item) {
+	p.sketch.increment(item.key)
+	switch item.segment {
+	case segmentWindow:
+		p.window.MoveToFront(item.element)
+	case segmentProbation:
+		p.probation.Remove(item.element)
+		item.segment = segmentProtected
+		item.element = p.protected.PushFront(item)
+		p.protectedSize += item.size
+		for p.protectedSize > p.protectedMax {
+			back := p.protected.Back()
+			demoted := back.Value.(*Item)
+			if demoted == item {
+				break
+			}
+			p.protected.Remove(back)
+			p.protectedSize -= demoted.size
+			demoted.segment = segmentProbation
+			demoted.element = p.probation.PushFront(demoted)
+		}
+	case segmentProtected:
+		p.protected.MoveToFront(item.element)
+	}
+}
+
+func (p *tinyLFU) Remove(item *Item) {
+	switch item.segment {
+	case segmentWindow:
+		p.window.Remove(item.element)
+		p.windowSize -= item.size
+	case segmentProbation:
+		p.probation.Remove(item.element)
+		p.mainSize -= item.size
+	case segmentProtected:
+		p.protected.Remove(item.element)
+		p.mainSize -= item.size
+		p.protectedSize -= item.size
+	}
+}
+
+func (p *tinyLFU) Evict(n int, evict func(item *Item) bool) {
+	for i := 0; i < n; i++ {
+		victim := p.victim()
+		var candidate *Item
+		if p.windowSize > p.windowMax || victim == nil {
+			if back := p.window.Back(); back != nil {
+				candidate = back.Value.(*Item)
+			}
+		}
+
+		switch {
+		case candidate == nil && victim == nil:
+			return
+		case candidate == nil:
+			p.tryEvict(victim, evict)
+		case victim == nil:
+			p.tryEvict(candidate, evict)
+		case p.sketch.estimate(candidate.key) > p.sketch.estimate(victim.key):
+			// the candidate is admitted in place of the victim
+			if p.tryEvict(victim, evict) {
+				p.window.Remove(candidate.element)
+				p.windowSize -= candidate.size
+				p.pushProbation(candidate)
+			}
+		default:
+			p.tryEvict(candidate, evict)
+		}
+	}
+}
+
+func (p *tinyLFU) Clear() {
+	p.sketch = newCMSketch(p.windowMax + p.mainMax)
+	p.window = list.New()
+	p.probation = list.New()
+	p.protected = list.New()
+	p.windowSize = 0
+	p.mainSize = 0
+	p.protectedSize = 0
+}
+
+// the item the main space would evict
+func (p *tinyLFU) victim() *Item {
+	if back := p.probation.Back(); back != nil {
+		return back.Value.(*Item)
+	}
+	if back := p.protected.Back(); back != nil {
+		return back.Value.(*Item)
+	}
+	return nil
+}
+
+func (p *tinyLFU) pushProbation(item *Item) {
+	item.segment = segmentProbation
+	item.element = p.probation.PushFront(item)
+	p.mainSize += item.size
+}
+
+func (p *tinyLFU) tryEvict(item *Item, evict func(item *Item) bool) bool {
+	if evict(item) {
+		p.Remove(item)
+		return true
+	}
+	// tracked item, keep it out of the way of the next evictions
+	switch item.segment {
+	case segmentWindow:
+		p.window.MoveToFront(item.element)
+	case segmentProbation:
+		p.probation.MoveToFront(item.element)
+	case segmentProtected:
+		p.protected.MoveToFront(item.element)
+	}
+	return false
+}
+
+// cmSketch is a count-min sketch of 4 rows of 4-bit counters.
+// Counters are halved once the number of increments reaches 10 times the
+// capacity, so that old frequencies fade out.
+type cmSketch struct {
+	rows      [4][]uint64
+	mask      uint64
+	additions int64
+	resetAt   int64
+}
+
+func newCMSketch(capacity int64) *cmSketch {
+	if capacity < 16 {
+		capacity = 16
+	}
+	// counters per row, a power of 2 for masking
+	counters := uint64(16)
+	for counters < uint64(capacity) {
+		counters <<= 1
+	}
+	s := &cmSketch{
+		mask:    counters - 1,
+		resetAt: capacity * 10,
+	}
+	for i := range s.rows {
+		// 16 counters of 4 bits per word
+		s.rows[i] = make([]uint64, counters/16)
+	}
+	return s
+}
+
+func (s *cmSketch) increment(key string) {
+	h1, h2 := sketchHash(key)
+	for i := range s.rows {
+		c := (h1 + uint64(i)*h2) & s.mask
+		word, shift := c>>4, (c&15)*4
+		if (s.rows[i][word]>>shift)&0xf < 0xf {
+			s.rows[i][word] += 1 << shift
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(key string) uint64 {
+	h1, h2 := sketchHash(key)
+	min := uint64(0xf)
+	for i := range s.rows {
+		c := (h1 + uint64(i)*h2) & s.mask
+		if v := (s.rows[i][c>>4] >> ((c & 15) * 4)) & 0xf; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for _, row := range s.rows {
+		for j := range row {
+			row[j] = (row[j] >> 1) & 0x7777777777777777
+		}
+	}
+	s.additions /= 2
+}
+
+// fnv-1a, and a second hash derived from it for double hashing
+func sketchHash(key string) (uint64, uint64) {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	h2 := h
+	h2 ^= h2 >> 33
+	h2 *= 0xff51afd7ed558ccd
+	h2 ^= h2 >> 33
+	return h, h2 | 1
+}
@@ -0,0 +1,76 @@
+package memorycache
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Typed wraps a Cache holding values of type V, so that callers don't need
+// to type-assert Item.Value().
+type Typed[V any] struct {
+	cache *Cache
+}
+
+// NewTyped wraps the cache. Values of other types set in the cache directly
+// are seen as missing by Typed.
+func NewTyped[V any](cache *Cache) *Typed[V] {
+	return &Typed[V]{cache: cache}
+}
+
+// Cache returns the wrapped cache.
+func (t *Typed[V]) Cache() *Cache {
+	return t.cache
+}
+
+// Get the value of the key. ok is false if the item wasn't found, is expired
+// or holds a value of another type.
+func (t *Typed[V]) Get(key string) (value V, ok bool) {
+	item := t.cache.Get(key)
+	if item == nil || item.Expired() {
+		return value, false
+	}
+	value, ok = item.Value().(V)
+	return value, ok
+}
+
+// Set the value in the cache for the specified duration, optionally tagged.
+func (t *Typed[V]) Set(key string, value V, duration time.Duration, tags ...string) {
+	t.cache.set(key, value, duration, tags...)
+}
+
+// Fetch the value from the cache and calls fetch on a miss.
+// The semantics are the same as for Cache.Fetch, a value of another type
+// is a miss and gets replaced by the value fetched.
+func (t *Typed[V]) Fetch(key string, duration time.Duration, fetch func() (V, error)) (V, error) {
+	var zero V
+	load := func() (interface{}, error) {
+		return fetch()
+	}
+	item, err := t.cache.Fetch(key, duration, load)
+	if err != nil {
+		return zero, err
+	}
+	if value, ok := item.Value().(V); ok {
+		return value, nil
+	}
+	t.cache.Delete(key)
+	if item, err = t.cache.Fetch(key, duration, load); err != nil {
+		return zero, err
+	}
+	if value, ok := item.Value().(V); ok {
+		return value, nil
+	}
+	// set again concurrently with another type
+	return zero, fmt.Errorf("memorycache: %s holds a %T, not a %s", key, item.Value(), reflect.TypeOf((*V)(nil)).Elem())
+}
+
+// Delete the key, return true if the item was present, false otherwise.
+func (t *Typed[V]) Delete(key string) bool {
+	return t.cache.Delete(key)
+}
+
+// InvalidateTag deletes all the items set with the tag.
+func (t *Typed[V]) InvalidateTag(tag string) int {
+	return t.cache.InvalidateTag(tag)
+}
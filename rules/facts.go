@@ -0,0 +1,106 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Get returns the fact at a dotted path, e.g. order.total.
+func (f Facts) Get(path string) (interface{}, bool) {
+	var v interface{} = map[string]interface{}(f)
+	for _, k := range strings.Split(path, ".") {
+		switch o := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = o[k]; !ok {
+				return nil, false
+			}
+		case Facts:
+			var ok bool
+			if v, ok = o[k]; !ok {
+				return nil, false
+			}
+		default:
+			rv := reflect.Indirect(reflect.ValueOf(v))
+			if rv.Kind() != reflect.Struct {
+				return nil, false
+			}
+			field := rv.FieldByName(k)
+			if !field.IsValid() || !field.CanInterface() {
+				return nil, false
+			}
+			v = field.Interface()
+		}
+	}
+	return v, true
+}
+
+// Set sets the fact at a dotted path. Missing objects are created as maps,
+// fields of struct pointers are set with numbers converted to the field type.
+func (f Facts) Set(path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	m := map[string]interface{}(f)
+	for i, k := range keys[:len(keys)-1] {
+		switch o := m[k].(type) {
+		case nil:
+			next := map[string]interface{}{}
+			m[k] = next
+			m = next
+		case map[string]interface{}:
+			m = o
+		case Facts:
+			m = o
+		default:
+			rv := reflect.ValueOf(o)
+			if rv.Kind() != reflect.Ptr || rv.IsNil() {
+				return fmt.Errorf("can not set %s: %s is a %T", path, strings.Join(keys[:i+1], "."), o)
+			}
+			return setField(rv.Elem(), keys[i+1:], value, path)
+		}
+	}
+	m[keys[len(keys)-1]] = value
+	return nil
+}
+
+func setField(v reflect.Value, keys []string, value interface{}, path string) error {
+	for _, k := range keys {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return fmt.Errorf("can not set %s: nil pointer", path)
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("can not set %s: %s is not a struct", path, v.Type())
+		}
+		v = v.FieldByName(k)
+		if !v.IsValid() || !v.CanSet() {
+			return fmt.Errorf("can not set %s: no settable field %s", path, k)
+		}
+	}
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Type().AssignableTo(v.Type()):
+		v.Set(rv)
+	case rv.Type().ConvertibleTo(v.Type()) && isNumber(rv.Kind()) == isNumber(v.Kind()):
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("can not set %s: %T is not assignable to %s", path, value, v.Type())
+	}
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
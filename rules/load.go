@@ -0,0 +1,56 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Parse decodes a rule set, format is yaml, yml, json or toml.
+func Parse(data []byte, format string) (*RuleSet, error) {
+	set := &RuleSet{}
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, set)
+	case "json":
+		err = json.Unmarshal(data, set)
+	case "toml":
+		err = toml.Unmarshal(data, set)
+	default:
+		return nil, fmt.Errorf("rules: unknown format %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rules: %v", err)
+	}
+	for i, r := range set.Rules {
+		if r == nil {
+			return nil, fmt.Errorf("rules: empty rule %d", i)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s#%d", set.Name, i)
+		}
+	}
+	return set, nil
+}
+
+// LoadFile reads a rule set, the format is the file extension.
+func LoadFile(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set, err := Parse(data, filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	if set.Name == "" {
+		set.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return set, nil
+}
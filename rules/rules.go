@@ -0,0 +1,316 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xpsuper/stl/eval"
+)
+
+// ErrMaxCycles is returned by Run when rules keep firing after MaxCycles cycles,
+// usually rules changing each other's facts back and forth.
+var ErrMaxCycles = errors.New("rules: max cycles exceeded")
+
+// Facts are the working memory of a run: conditions and actions read them as
+// variables and assignments write them.
+type Facts map[string]interface{}
+
+// Action is called when a rule fires.
+type Action func(ctx context.Context, facts Facts) error
+
+// Rule fires its actions when its condition evaluates to true.
+type Rule struct {
+	Name        string `json:"name" yaml:"name" toml:"name"`
+	Description string `json:"description" yaml:"description" toml:"description"`
+	// Rules with a higher salience are tried first, rules of the same
+	// salience in the order they were added
+	Salience int `json:"salience" yaml:"salience" toml:"salience"`
+	// eval expression, e.g. order.total > 100 && customer.vip
+	Condition string `json:"condition" yaml:"condition" toml:"condition"`
+	// Each action is an assignment (order.discount = order.total * 0.1),
+	// the name of an action registered with Engine.Action, or an expression
+	// evaluated for the functions it calls.
+	Actions []string `json:"actions" yaml:"actions" toml:"actions"`
+	// Fires at most once per run
+	Once bool `json:"once" yaml:"once" toml:"once"`
+	// Doesn't fire again because of the changes made by its own actions
+	NoLoop bool `json:"noLoop" yaml:"noLoop" toml:"noLoop"`
+	// Called after Actions
+	Then Action `json:"-" yaml:"-" toml:"-"`
+}
+
+// RuleSet is a named list of rules, as loaded by Parse and LoadFile.
+type RuleSet struct {
+	Name  string  `json:"name" yaml:"name" toml:"name"`
+	Rules []*Rule `json:"rules" yaml:"rules" toml:"rules"`
+}
+
+// Result of a run.
+type Result struct {
+	// Names of the fired rules, in firing order
+	Fired []string
+	// Number of fired rules
+	Cycles int
+}
+
+// Engine runs rules against facts, Run can be called concurrently.
+type Engine struct {
+	language  eval.Language
+	maxCycles int
+
+	// rules and actions are copied on write, a run uses the ones it started with
+	mu      sync.RWMutex
+	rules   []*compiledRule
+	actions map[string]Action
+	added   int
+}
+
+type compiledRule struct {
+	*Rule
+	order     int
+	condition eval.Evaluable
+	// paths of the variables read by the condition, cut at the dynamic keys
+	reads   []string
+	actions []action
+}
+
+type action struct {
+	source string
+	// assignment target, or name of the registered action
+	target []string
+	name   string
+	value  eval.Evaluable
+}
+
+// NewEngine creates an engine evaluating rules with language,
+// eval.Full(eval.Stdlib()) if none is given.
+func NewEngine(language ...eval.Language) *Engine {
+	l := eval.Full(eval.Stdlib())
+	if len(language) > 0 {
+		l = language[0]
+	}
+	return &Engine{
+		language:  l,
+		maxCycles: 100,
+		actions:   map[string]Action{},
+	}
+}
+
+// MaxCycles is the maximum number of rules fired by a run.
+// [100]
+func (e *Engine) MaxCycles(max int) *Engine {
+	e.maxCycles = max
+	return e
+}
+
+// Action registers an action which rules call by name.
+func (e *Engine) Action(name string, action Action) *Engine {
+	e.mu.Lock()
+	actions := make(map[string]Action, len(e.actions)+1)
+	for k, v := range e.actions {
+		actions[k] = v
+	}
+	actions[name] = action
+	e.actions = actions
+	e.mu.Unlock()
+	return e
+}
+
+// AddRuleSet adds the rules of a rule set.
+func (e *Engine) AddRuleSet(set *RuleSet) error {
+	return e.Add(set.Rules...)
+}
+
+// Add compiles and adds rules. No rule is added if one of them doesn't compile.
+func (e *Engine) Add(rules ...*Rule) error {
+	compiled := make([]*compiledRule, 0, len(rules))
+	for _, r := range rules {
+		c, err := e.compile(r)
+		if err != nil {
+			return fmt.Errorf("rules: %s: %v", r.Name, err)
+		}
+		compiled = append(compiled, c)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	all := append([]*compiledRule(nil), e.rules...)
+	for _, c := range compiled {
+		e.added++
+		c.order = e.added
+		all = append(all, c)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Salience != all[j].Salience {
+			return all[i].Salience > all[j].Salience
+		}
+		return all[i].order < all[j].order
+	})
+	e.rules = all
+	return nil
+}
+
+// Remove removes the rules with the given name.
+func (e *Engine) Remove(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var rules []*compiledRule
+	for _, r := range e.rules {
+		if r.Name != name {
+			rules = append(rules, r)
+		}
+	}
+	e.rules = rules
+}
+
+var assignment = regexp.MustCompile(`^\s*([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)\s*=([^=~].*)$`)
+var actionName = regexp.MustCompile(`^\s*[A-Za-z_]\w*\s*$`)
+
+func (e *Engine) compile(r *Rule) (*compiledRule, error) {
+	if r.Condition == "" {
+		return nil, errors.New("empty condition")
+	}
+	condition, err := e.language.Compile(r.Condition)
+	if err != nil {
+		return nil, err
+	}
+	analysis, err := e.language.Analyze(r.Condition, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiledRule{Rule: r, condition: condition}
+	seen := map[string]bool{}
+	for _, v := range analysis.Variables {
+		if i := strings.Index(v, ".*"); i >= 0 {
+			v = v[:i]
+		}
+		if !seen[v] {
+			seen[v] = true
+			c.reads = append(c.reads, v)
+		}
+	}
+	for _, source := range r.Actions {
+		a := action{source: source}
+		switch {
+		case assignment.MatchString(source):
+			m := assignment.FindStringSubmatch(source)
+			a.target = strings.Split(m[1], ".")
+			a.value, err = e.language.Compile(m[2])
+		case actionName.MatchString(source):
+			a.name = strings.TrimSpace(source)
+		default:
+			a.value, err = e.language.Compile(source)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("action %q: %v", source, err)
+		}
+		c.actions = append(c.actions, a)
+	}
+	return c, nil
+}
+
+// Run fires the rules until none of them matches: each cycle fires the first
+// rule, by salience, whose condition is true. A rule doesn't fire twice on the
+// same facts, it fires again once the facts read by its condition changed.
+// Run returns ErrMaxCycles once MaxCycles rules fired.
+func (e *Engine) Run(ctx context.Context, facts Facts) (*Result, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e.mu.RLock()
+	rules := e.rules
+	actions := e.actions
+	e.mu.RUnlock()
+
+	// the facts each rule fired on, "" if it never fired or its condition became false
+	fired := make([]string, len(rules))
+	once := make([]bool, len(rules))
+	result := &Result{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		next := -1
+		var print string
+		for i, r := range rules {
+			if r.Once && once[i] {
+				continue
+			}
+			ok, err := r.condition.EvalBool(ctx, map[string]interface{}(facts))
+			if err != nil {
+				return result, fmt.Errorf("rules: %s: %w", r.Name, err)
+			}
+			if !ok {
+				fired[i] = ""
+				continue
+			}
+			if print = r.fingerprint(facts); print != fired[i] {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return result, nil
+		}
+		if result.Cycles >= e.maxCycles {
+			return result, fmt.Errorf("%w: %d rules fired, last %s", ErrMaxCycles, result.Cycles, rules[next].Name)
+		}
+
+		r := rules[next]
+		fired[next], once[next] = print, true
+		if err := r.fire(ctx, facts, actions); err != nil {
+			return result, fmt.Errorf("rules: %s: %w", r.Name, err)
+		}
+		if r.NoLoop {
+			fired[next] = r.fingerprint(facts)
+		}
+		result.Fired = append(result.Fired, r.Name)
+		result.Cycles++
+	}
+}
+
+// fingerprint of the facts read by the condition
+func (r *compiledRule) fingerprint(facts Facts) string {
+	var b strings.Builder
+	b.WriteByte('#')
+	for _, path := range r.reads {
+		v, _ := facts.Get(path)
+		fmt.Fprintf(&b, "%s=%+v;", path, v)
+	}
+	return b.String()
+}
+
+func (r *compiledRule) fire(ctx context.Context, facts Facts, actions map[string]Action) error {
+	for _, a := range r.actions {
+		switch {
+		case a.name != "":
+			f, ok := actions[a.name]
+			if !ok {
+				return fmt.Errorf("unknown action %s", a.name)
+			}
+			if err := f(ctx, facts); err != nil {
+				return err
+			}
+		case a.target != nil:
+			v, err := a.value(ctx, map[string]interface{}(facts))
+			if err != nil {
+				return fmt.Errorf("action %q: %w", a.source, err)
+			}
+			if err = facts.Set(strings.Join(a.target, "."), v); err != nil {
+				return fmt.Errorf("action %q: %w", a.source, err)
+			}
+		default:
+			if _, err := a.value(ctx, map[string]interface{}(facts)); err != nil {
+				return fmt.Errorf("action %q: %w", a.source, err)
+			}
+		}
+	}
+	if r.Then != nil {
+		return r.Then(ctx, facts)
+	}
+	return nil
+}
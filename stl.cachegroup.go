@@ -0,0 +1,321 @@
+package stl
+
+/*********调用示例********
+func main() {
+    self := "http://10.0.0.1:8080/_cache/users/"
+    group := NewCacheGroup("users", self, func(ctx context.Context, key string) ([]byte, error) {
+        return loadUserFromDB(ctx, key)
+    }, nil)
+    group.SetPeers(self, "http://10.0.0.2:8080/_cache/users/", "http://10.0.0.3:8080/_cache/users/")
+
+    http.Handle("/_cache/users/", group)
+    go http.ListenAndServe(":8080", nil)
+
+    data, err := group.Get(context.Background(), "user:4")
+}
+ ************************/
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xpsuper/stl/memorycache"
+)
+
+// CacheGetter 本节点负责的Key未命中缓存时，从数据源加载数据
+type CacheGetter func(ctx gocontext.Context, key string) ([]byte, error)
+
+// CachePeerTransport 从其他节点获取Key的数据
+type CachePeerTransport interface {
+	Fetch(ctx gocontext.Context, peer, key string) ([]byte, error)
+}
+
+// CacheGroupOptions 缓存组配置，零值字段使用默认值
+type CacheGroupOptions struct {
+	// 每个节点在哈希环上的虚拟节点数量 [50]
+	Replicas int
+	// 哈希环使用的哈希函数 [crc32.ChecksumIEEE]
+	Hash Hash
+	// 本节点负责的Key的缓存时长 [10分钟]
+	TTL time.Duration
+	// 其他节点负责的Key在本地热点缓存的时长，小于0表示不缓存 [1分钟]
+	HotTTL time.Duration
+	// 本节点负责的Key的缓存配置 [memorycache.Configure()]
+	Cache *memorycache.Configuration
+	// 热点缓存配置 [memorycache.Configure().MaxSize(500)]
+	HotCache *memorycache.Configuration
+	// 节点间传输 [HttpCachePeerTransport]
+	Transport CachePeerTransport
+	// 共享加载的超时时间，数据源加载和节点间获取都不使用调用方的context [10秒]
+	LoadTimeout time.Duration
+}
+
+// CacheGroup 分布式缓存组
+// 每个Key通过一致性Hash归属到一个节点，只有归属节点从数据源加载数据，
+// 其他节点通过Transport向归属节点获取，并保存在本地热点缓存中。
+// 同一个Key的并发请求只会加载一次，加载在独立的context上进行，
+// 调用方的取消和超时只结束自己的等待，不会影响其他等待的请求。
+type CacheGroup struct {
+	name      string
+	self      string
+	getter    CacheGetter
+	transport CachePeerTransport
+	replicas  int
+	hash      Hash
+	ttl       time.Duration
+	hotTTL    time.Duration
+	timeout   time.Duration
+
+	mu    sync.RWMutex
+	peers *ConsistencyHash
+	loads sync.WaitGroup
+
+	main *memorycache.Cache
+	hot  *memorycache.Cache
+}
+
+// NewCacheGroup 创建缓存组
+// self 为本节点地址，需要与SetPeers中本节点的地址一致
+func NewCacheGroup(name, self string, getter CacheGetter, options *CacheGroupOptions) *CacheGroup {
+	if options == nil {
+		options = &CacheGroupOptions{}
+	}
+	g := &CacheGroup{
+		name:      name,
+		self:      self,
+		getter:    getter,
+		transport: options.Transport,
+		replicas:  options.Replicas,
+		hash:      options.Hash,
+		ttl:       options.TTL,
+		hotTTL:    options.HotTTL,
+		timeout:   options.LoadTimeout,
+	}
+	if g.transport == nil {
+		g.transport = &HttpCachePeerTransport{}
+	}
+	if g.replicas <= 0 {
+		g.replicas = 50
+	}
+	if g.ttl <= 0 {
+		g.ttl = 10 * time.Minute
+	}
+	if g.hotTTL == 0 {
+		g.hotTTL = time.Minute
+	}
+	if g.timeout <= 0 {
+		g.timeout = 10 * time.Second
+	}
+	g.peers = NewConsistencyHash(g.replicas, g.hash)
+
+	mainConfig := options.Cache
+	if mainConfig == nil {
+		mainConfig = memorycache.Configure()
+	}
+	g.main = memorycache.NewCache(mainConfig)
+	if g.hotTTL > 0 {
+		hotConfig := options.HotCache
+		if hotConfig == nil {
+			hotConfig = memorycache.Configure().MaxSize(500)
+		}
+		g.hot = memorycache.NewCache(hotConfig)
+	}
+	return g
+}
+
+// Name 缓存组名称
+func (g *CacheGroup) Name() string {
+	return g.name
+}
+
+// SetPeers 设置所有节点（包括本节点），替换之前的节点
+func (g *CacheGroup) SetPeers(peers ...string) {
+	ring := NewConsistencyHash(g.replicas, g.hash)
+	ring.Add(peers...)
+	g.mu.Lock()
+	g.peers = ring
+	g.mu.Unlock()
+}
+
+// Owner 获取Key的归属节点，没有设置节点时返回本节点
+func (g *CacheGroup) Owner(key string) string {
+	g.mu.RLock()
+	owner := g.peers.Get(key)
+	g.mu.RUnlock()
+	if owner == "" {
+		return g.self
+	}
+	return owner
+}
+
+// Get 获取Key的数据，返回的是缓存数据的副本，调用方可以修改
+// 本节点负责的Key从本地缓存或数据源获取，其他Key从归属节点获取，
+// 归属节点不可用时退回到从数据源获取。
+func (g *CacheGroup) Get(ctx gocontext.Context, key string) ([]byte, error) {
+	owner := g.Owner(key)
+	if owner == g.self {
+		return g.load(ctx, key)
+	}
+	if g.hot == nil {
+		data, err := g.transport.Fetch(ctx, owner, key)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return g.load(ctx, key)
+		}
+		return data, nil
+	}
+	data, err := g.fetch(ctx, g.hot, key, g.hotTTL, func(loadCtx gocontext.Context) ([]byte, error) {
+		return g.transport.Fetch(loadCtx, owner, key)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return g.load(ctx, key)
+	}
+	return data, nil
+}
+
+// Remove 删除本节点缓存中的Key，包括热点缓存
+func (g *CacheGroup) Remove(key string) {
+	g.main.Delete(key)
+	if g.hot != nil {
+		g.hot.Delete(key)
+	}
+}
+
+// ServeHTTP 响应其他节点的请求，请求路径的最后一段为转义后的Key
+// 只从本节点加载，不会再转发到其他节点
+func (g *CacheGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.EscapedPath()
+	key, err := url.PathUnescape(path[strings.LastIndex(path, "/")+1:])
+	if err != nil || key == "" {
+		http.Error(w, "bad key", http.StatusBadRequest)
+		return
+	}
+	data, err := g.load(r.Context(), key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(data)
+}
+
+// Stop 等待进行中的加载结束后停止缓存的后台任务，之后不能再使用
+func (g *CacheGroup) Stop() {
+	g.loads.Wait()
+	g.main.Stop()
+	if g.hot != nil {
+		g.hot.Stop()
+	}
+}
+
+func (g *CacheGroup) load(ctx gocontext.Context, key string) ([]byte, error) {
+	return g.fetch(ctx, g.main, key, g.ttl, func(loadCtx gocontext.Context) ([]byte, error) {
+		return g.getter(loadCtx, key)
+	})
+}
+
+type cacheFetchResult struct {
+	data     []byte
+	err      error
+	panicked interface{}
+}
+
+// fetch 通过cache合并同一个Key的加载，load在不受调用方影响的context上执行，
+// 以免一个调用方的取消让所有等待的请求失败，或者被当作加载失败缓存下来。
+// 调用方的ctx结束时立即返回ctx.Err()，加载继续进行并写入缓存。
+// 命中未过期的缓存时直接返回，不启动加载的goroutine。
+func (g *CacheGroup) fetch(ctx gocontext.Context, cache *memorycache.Cache, key string, ttl time.Duration, load func(loadCtx gocontext.Context) ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if item := cache.Get(key); item != nil && !item.Expired() {
+		if data, ok := item.Value().([]byte); ok {
+			return cloneBytes(data), nil
+		}
+	}
+	done := make(chan cacheFetchResult, 1)
+	g.loads.Add(1)
+	go func() {
+		defer g.loads.Done()
+		var result cacheFetchResult
+		defer func() {
+			if r := recover(); r != nil {
+				result.panicked = r
+			}
+			done <- result
+		}()
+		item, err := cache.Fetch(key, ttl, func() (interface{}, error) {
+			loadCtx, cancel := gocontext.WithTimeout(gocontext.Background(), g.timeout)
+			defer cancel()
+			return load(loadCtx)
+		})
+		if err != nil {
+			result.err = err
+			return
+		}
+		result.data = item.Value().([]byte)
+	}()
+	select {
+	case result := <-done:
+		if result.panicked != nil {
+			panic(result.panicked)
+		}
+		if result.err != nil {
+			return nil, result.err
+		}
+		return cloneBytes(result.data), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// cloneBytes 复制缓存中的数据，所有调用方共享缓存中的切片，不能交给调用方修改
+func cloneBytes(data []byte) []byte {
+	return append([]byte(nil), data...)
+}
+
+// HttpCachePeerTransport 通过HTTP获取其他节点的数据
+// 节点地址为其缓存组Handler的URL，Key转义后拼接在后面
+type HttpCachePeerTransport struct {
+	// 为空时使用 http.DefaultClient
+	Client *http.Client
+}
+
+func (t *HttpCachePeerTransport) Fetch(ctx gocontext.Context, peer, key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(peer, "/")+"/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cache peer %s: %s: %s", peer, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
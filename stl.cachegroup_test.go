@@ -0,0 +1,212 @@
+package stl
+
+import (
+	gocontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xpsuper/stl/memorycache"
+)
+
+type testCachePeer struct {
+	group  *CacheGroup
+	server *httptest.Server
+	loads  int64
+}
+
+// newTestCachePeers starts n groups served by httptest servers, peers of each other
+func newTestCachePeers(t *testing.T, n int, getter CacheGetter, options *CacheGroupOptions) []*testCachePeer {
+	peers := make([]*testCachePeer, n)
+	urls := make([]string, n)
+	for i := range peers {
+		p := &testCachePeer{}
+		mux := http.NewServeMux()
+		p.server = httptest.NewServer(mux)
+		self := p.server.URL + "/_cache/test/"
+		p.group = NewCacheGroup("test", self, func(ctx gocontext.Context, key string) ([]byte, error) {
+			atomic.AddInt64(&p.loads, 1)
+			return getter(ctx, key)
+		}, options)
+		mux.Handle("/_cache/test/", p.group)
+		peers[i] = p
+		urls[i] = self
+	}
+	for _, p := range peers {
+		p.group.SetPeers(urls...)
+	}
+	t.Cleanup(func() {
+		for _, p := range peers {
+			p.server.Close()
+			p.group.Stop()
+		}
+	})
+	return peers
+}
+
+func TestCacheGroupLoadsOnOwner(t *testing.T) {
+	peers := newTestCachePeers(t, 3, func(ctx gocontext.Context, key string) ([]byte, error) {
+		return []byte("value of " + key), nil
+	}, nil)
+
+	for i := 0; i < 30; i++ {
+		key := "key/" + strconv.Itoa(i)
+		for _, p := range peers {
+			data, err := p.group.Get(gocontext.Background(), key)
+			if err != nil {
+				t.Fatalf("Get(%q): %v", key, err)
+			}
+			if string(data) != "value of "+key {
+				t.Fatalf("Get(%q) = %q", key, data)
+			}
+		}
+	}
+
+	var total int64
+	for _, p := range peers {
+		loads := atomic.LoadInt64(&p.loads)
+		if loads == 0 {
+			t.Errorf("peer %s loaded no key", p.server.URL)
+		}
+		total += loads
+	}
+	if total != 30 {
+		t.Errorf("keys loaded %d times, want each key loaded once by its owner", total)
+	}
+}
+
+func TestCacheGroupOwnerDown(t *testing.T) {
+	peers := newTestCachePeers(t, 2, func(ctx gocontext.Context, key string) ([]byte, error) {
+		return []byte(key), nil
+	}, nil)
+
+	var key string
+	for i := 0; ; i++ {
+		key = strconv.Itoa(i)
+		if peers[0].group.Owner(key) != peers[0].group.self {
+			break
+		}
+	}
+	peers[1].server.Close()
+
+	data, err := peers[0].group.Get(gocontext.Background(), key)
+	if err != nil || string(data) != key {
+		t.Fatalf("Get = %q, %v, want the value loaded locally", data, err)
+	}
+	if loads := atomic.LoadInt64(&peers[0].loads); loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+}
+
+func TestCacheGroupCallerCancel(t *testing.T) {
+	release := make(chan struct{})
+	var loadErr atomic.Value
+	peers := newTestCachePeers(t, 1, func(ctx gocontext.Context, key string) ([]byte, error) {
+		select {
+		case <-release:
+			return []byte(key), nil
+		case <-ctx.Done():
+			loadErr.Store(ctx.Err())
+			return nil, ctx.Err()
+		}
+	}, &CacheGroupOptions{Cache: memorycache.Configure().NegativeTTL(time.Minute)})
+	group := peers[0].group
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := group.Get(ctx, "k")
+		first <- err
+	}()
+	for atomic.LoadInt64(&peers[0].loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	var data []byte
+	var err error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		data, err = group.Get(gocontext.Background(), "k")
+	}()
+
+	cancel()
+	if err := <-first; !errors.Is(err, gocontext.Canceled) {
+		t.Fatalf("canceled caller got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if err != nil || string(data) != "k" {
+		t.Fatalf("waiting caller got %q, %v", data, err)
+	}
+	if v := loadErr.Load(); v != nil {
+		t.Errorf("the shared load was canceled: %v", v)
+	}
+	if loads := atomic.LoadInt64(&peers[0].loads); loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+
+	data, err = group.Get(gocontext.Background(), "k")
+	if err != nil || string(data) != "k" {
+		t.Fatalf("Get after cancel = %q, %v, want the cached value", data, err)
+	}
+}
+
+func TestCacheGroupLoadTimeout(t *testing.T) {
+	peers := newTestCachePeers(t, 1, func(ctx gocontext.Context, key string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, &CacheGroupOptions{LoadTimeout: 20 * time.Millisecond})
+
+	_, err := peers[0].group.Get(gocontext.Background(), "k")
+	if !errors.Is(err, gocontext.DeadlineExceeded) {
+		t.Fatalf("Get = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCacheGroupHit(t *testing.T) {
+	peers := newTestCachePeers(t, 1, func(ctx gocontext.Context, key string) ([]byte, error) {
+		return []byte("value"), nil
+	}, nil)
+	group := peers[0].group
+
+	data, err := group.Get(gocontext.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] = 'X'
+
+	data, err = group.Get(gocontext.Background(), "k")
+	if err != nil || string(data) != "value" {
+		t.Fatalf("Get = %q, %v, want the value unchanged by the caller", data, err)
+	}
+	if loads := atomic.LoadInt64(&peers[0].loads); loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+}
+
+// BenchmarkCacheGroupHit gets a key of the local cache, a hit doesn't start the goroutine of a load
+func BenchmarkCacheGroupHit(b *testing.B) {
+	group := NewCacheGroup("bench", "self", func(ctx gocontext.Context, key string) ([]byte, error) {
+		return []byte("value"), nil
+	}, nil)
+	defer group.Stop()
+	ctx := gocontext.Background()
+	if _, err := group.Get(ctx, "k"); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := group.Get(ctx, "k"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package stl
 
 import (
+	"bufio"
 	"bytes"
 	"crypto"
 	"crypto/aes"
@@ -9,13 +10,30 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
-	"crypto/x509"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/sm3"
+	"github.com/tjfoc/gmsm/sm4"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 )
 
 type XPEncryptImpl struct {
@@ -122,38 +140,42 @@ func bytesCombine(pBytes ...[]byte) []byte {
 
 /**
  * 获取公钥
- * @param file_path string 公钥路径 ex: ./conf/keys/public_key.pem
+ * @param file_path string 公钥路径，PKIX、PKCS#1、证书或 OpenSSH 格式 ex: ./conf/keys/public_key.pem
  */
-func GetPublicKey(filePath string) (*rsa.PublicKey, error){
-	var publicKey *rsa.PublicKey
-	pubData, err := ioutil.ReadFile(filePath)
-	//解析证书
-	block, _ := pem.Decode([]byte(pubData))
-	cert, err := x509.ParsePKIXPublicKey(block.Bytes)
+func GetPublicKey(filePath string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ParsePublicKeyPEM(data)
 	if err != nil {
-		fmt.Println(err.Error())
-		return publicKey, err
+		return nil, err
+	}
+	publicKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("encrypt: %s is not an RSA public key", filePath)
 	}
-	publicKey = cert.(*rsa.PublicKey)
 	return publicKey, nil
 }
 
 /**
  * 获取私钥
- * @param file_path string 私钥路径 ex: ./conf/keys/private_key.pem
+ * @param file_path string 私钥路径，PKCS#1 或 PKCS#8 格式 ex: ./conf/keys/private_key.pem
+ * 加密的私钥使用 ParsePrivateKeyPEM
  */
 func GetPrivateKey(filePath string) (*rsa.PrivateKey, error) {
-	pfxData, err := ioutil.ReadFile(filePath)
+	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	//解析证书
-	block, _ := pem.Decode(pfxData)
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	key, err := ParsePrivateKeyPEM(data, nil)
 	if err != nil {
-		fmt.Println("解析证书错误" + err.Error())
 		return nil, err
 	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("encrypt: %s is not an RSA private key", filePath)
+	}
 	return privateKey, nil
 }
 
@@ -375,3 +397,829 @@ func (instance *XPEncryptImpl) AESDecrypt(crypt string, key, iv []byte) (string,
 	return string(origData), nil
 }
 /*********************** AES ********************/
+
+/*********************** AEAD ********************/
+// AEADAlgorithm 认证加密算法，同时保证数据的机密性和完整性
+type AEADAlgorithm byte
+
+const (
+	// AEADAESGCM AES-GCM，密钥 16、24 或 32 字节，CPU 支持 AES-NI 时最快
+	AEADAESGCM AEADAlgorithm = 1
+	// AEADChaCha20Poly1305 ChaCha20-Poly1305，密钥 32 字节，没有 AES 硬件加速时更快
+	AEADChaCha20Poly1305 AEADAlgorithm = 2
+	// AEADSM4GCM SM4-GCM，密钥 16 字节，国密合规时使用
+	AEADSM4GCM AEADAlgorithm = 3
+)
+
+const (
+	aeadEnvelopeVersion = 0x01
+	// 流式加密格式的版本，最高位区分于 Seal 的格式
+	aeadStreamVersion = 0x81
+	// 流式加密每块明文的大小
+	aeadStreamChunkSize = 64 * 1024
+	// 解密时接受的最大块，避免恶意的块大小耗尽内存
+	aeadStreamMaxChunkSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrAEADAuthentication 密文被篡改、密钥错误或附加数据不一致
+	ErrAEADAuthentication = errors.New("encrypt: message authentication failed")
+	// ErrAEADFormat 密文格式错误或版本不支持
+	ErrAEADFormat = errors.New("encrypt: invalid ciphertext format")
+	// ErrAEADAlgorithm 不支持的算法
+	ErrAEADAlgorithm = errors.New("encrypt: unsupported AEAD algorithm")
+	// ErrAEADTruncated 流式密文被截断，缺少最后一块
+	ErrAEADTruncated = errors.New("encrypt: truncated stream")
+)
+
+func newAEAD(alg AEADAlgorithm, key []byte) (cipher.AEAD, error) {
+	switch alg {
+	case AEADAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AEADChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case AEADSM4GCM:
+		block, err := sm4.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+	return nil, ErrAEADAlgorithm
+}
+
+// aeadSeal 加密并把随机 nonce 放在密文之前
+func aeadSeal(aead cipher.AEAD, dst, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+func aeadOpen(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrAEADFormat
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrAEADAuthentication
+	}
+	return plaintext, nil
+}
+
+// AESGCMEncrypt AES-GCM 加密，返回 nonce(12字节) + 密文 + tag(16字节)
+// nonce 随机生成，同一个密钥加密的消息不应超过 2^32 条
+// additionalData 为附加数据，不加密但参与认证，如记录的 ID，解密时必须一致，可以为 nil
+func (instance *XPEncryptImpl) AESGCMEncrypt(plaintext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(AEADAESGCM, key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadSeal(aead, nil, plaintext, additionalData)
+}
+
+// AESGCMDecrypt 解密 AESGCMEncrypt 的密文，认证失败时返回 ErrAEADAuthentication
+func (instance *XPEncryptImpl) AESGCMDecrypt(ciphertext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(AEADAESGCM, key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadOpen(aead, ciphertext, additionalData)
+}
+
+// ChaCha20Poly1305Encrypt ChaCha20-Poly1305 加密，返回 nonce(12字节) + 密文 + tag(16字节)，key 为 32 字节
+func (instance *XPEncryptImpl) ChaCha20Poly1305Encrypt(plaintext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(AEADChaCha20Poly1305, key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadSeal(aead, nil, plaintext, additionalData)
+}
+
+// ChaCha20Poly1305Decrypt 解密 ChaCha20Poly1305Encrypt 的密文，认证失败时返回 ErrAEADAuthentication
+func (instance *XPEncryptImpl) ChaCha20Poly1305Decrypt(ciphertext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(AEADChaCha20Poly1305, key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadOpen(aead, ciphertext, additionalData)
+}
+
+// Seal 加密为带版本的信封格式，密文记录了算法，Open 不需要指定算法:
+// 版本(1字节) + 算法(1字节) + 密钥ID长度(1字节) + 密钥ID + nonce + 密文 + tag
+// 信封头部参与认证，以后更换格式或算法时旧的密文仍然可以解密
+func (instance *XPEncryptImpl) Seal(alg AEADAlgorithm, key, plaintext, additionalData []byte) ([]byte, error) {
+	return instance.SealWithKeyID(alg, "", key, plaintext, additionalData)
+}
+
+// SealWithKeyID 同 Seal，信封中记录 keyID (最长255字节)，密钥轮换后由 EnvelopeKeyID 找到加密时的密钥
+func (instance *XPEncryptImpl) SealWithKeyID(alg AEADAlgorithm, keyID string, key, plaintext, additionalData []byte) ([]byte, error) {
+	if len(keyID) > 255 {
+		return nil, errors.New("encrypt: key ID longer than 255 bytes")
+	}
+	aead, err := newAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte{aeadEnvelopeVersion, byte(alg), byte(len(keyID))}, keyID...)
+	return aeadSeal(aead, header, plaintext, bytesCombine(header, additionalData))
+}
+
+// EnvelopeKeyID 返回 SealWithKeyID 记录的密钥ID，Seal 的信封为空字符串
+func (instance *XPEncryptImpl) EnvelopeKeyID(envelope []byte) (string, error) {
+	header, err := envelopeHeader(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(header[3:]), nil
+}
+
+func envelopeHeader(envelope []byte) ([]byte, error) {
+	if len(envelope) < 3 || envelope[0] != aeadEnvelopeVersion {
+		return nil, ErrAEADFormat
+	}
+	size := 3 + int(envelope[2])
+	if len(envelope) < size {
+		return nil, ErrAEADFormat
+	}
+	return envelope[:size], nil
+}
+
+// Open 解密 Seal 和 SealWithKeyID 的信封
+func (instance *XPEncryptImpl) Open(key, envelope, additionalData []byte) ([]byte, error) {
+	header, err := envelopeHeader(envelope)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(AEADAlgorithm(header[1]), key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadOpen(aead, envelope[len(header):], bytesCombine(header, additionalData))
+}
+
+// EncryptStream 分块加密 src 写入 dst，用于不能整个读入内存的大文件
+// 每块 64KB 明文单独认证，nonce 由随机前缀、块序号和最后一块的标记组成，
+// 块被删除、调换顺序或截断时解密失败
+func (instance *XPEncryptImpl) EncryptStream(dst io.Writer, src io.Reader, alg AEADAlgorithm, key, additionalData []byte) error {
+	aead, err := newAEAD(alg, key)
+	if err != nil {
+		return err
+	}
+
+	// 版本(1字节) + 算法(1字节) + 块大小(4字节) + nonce 前缀
+	header := make([]byte, 6+aead.NonceSize()-5)
+	header[0], header[1] = aeadStreamVersion, byte(alg)
+	binary.BigEndian.PutUint32(header[2:6], aeadStreamChunkSize)
+	if _, err = rand.Read(header[6:]); err != nil {
+		return err
+	}
+	if _, err = dst.Write(header); err != nil {
+		return err
+	}
+
+	stream := newAEADStream(aead, header, additionalData)
+	reader := bufio.NewReaderSize(src, aeadStreamChunkSize)
+	chunk := make([]byte, aeadStreamChunkSize)
+	var sealed []byte
+
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, err = reader.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if sealed, err = stream.seal(sealed[:0], chunk[:n], last); err != nil {
+			return err
+		}
+		if _, err = dst.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// DecryptStream 解密 EncryptStream 的密文写入 dst
+// 每块认证后即写入 dst，返回错误时已写入的数据不可信，应当丢弃
+func (instance *XPEncryptImpl) DecryptStream(dst io.Writer, src io.Reader, key, additionalData []byte) error {
+	reader := bufio.NewReaderSize(src, aeadStreamChunkSize)
+
+	prefix := make([]byte, 6)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		return ErrAEADFormat
+	}
+	if prefix[0] != aeadStreamVersion {
+		return ErrAEADFormat
+	}
+	aead, err := newAEAD(AEADAlgorithm(prefix[1]), key)
+	if err != nil {
+		return err
+	}
+	chunkSize := binary.BigEndian.Uint32(prefix[2:6])
+	if chunkSize == 0 || chunkSize > aeadStreamMaxChunkSize {
+		return ErrAEADFormat
+	}
+
+	header := make([]byte, 6+aead.NonceSize()-5)
+	copy(header, prefix)
+	if _, err = io.ReadFull(reader, header[6:]); err != nil {
+		return ErrAEADFormat
+	}
+
+	stream := newAEADStream(aead, header, additionalData)
+	chunk := make([]byte, int(chunkSize)+aead.Overhead())
+	var plaintext []byte
+
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if err == io.EOF {
+			return ErrAEADTruncated
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, err = reader.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if plaintext, err = stream.open(plaintext[:0], chunk[:n], last); err != nil {
+			return err
+		}
+		if _, err = dst.Write(plaintext); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// aeadStream 流式加密的状态，nonce 为 前缀 + 块序号(4字节) + 最后一块标记(1字节)
+type aeadStream struct {
+	aead           cipher.AEAD
+	nonce          []byte
+	additionalData []byte
+	counter        uint32
+	done           bool
+}
+
+func newAEADStream(aead cipher.AEAD, header, additionalData []byte) *aeadStream {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, header[6:])
+	return &aeadStream{aead: aead, nonce: nonce, additionalData: bytesCombine(header, additionalData)}
+}
+
+func (s *aeadStream) next(last bool) ([]byte, error) {
+	if s.done {
+		return nil, ErrAEADFormat
+	}
+	size := len(s.nonce)
+	binary.BigEndian.PutUint32(s.nonce[size-5:size-1], s.counter)
+	s.nonce[size-1] = 0
+	if last {
+		s.nonce[size-1] = 1
+		s.done = true
+	}
+	if s.counter++; s.counter == 0 {
+		return nil, errors.New("encrypt: stream too long")
+	}
+	return s.nonce, nil
+}
+
+func (s *aeadStream) seal(dst, chunk []byte, last bool) ([]byte, error) {
+	nonce, err := s.next(last)
+	if err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(dst, nonce, chunk, s.additionalData), nil
+}
+
+func (s *aeadStream) open(dst, chunk []byte, last bool) ([]byte, error) {
+	nonce, err := s.next(last)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.aead.Open(dst, nonce, chunk, s.additionalData)
+	if err != nil {
+		return nil, ErrAEADAuthentication
+	}
+	return plaintext, nil
+}
+/*********************** AEAD ********************/
+
+/*********************** Password ********************/
+// PasswordAlgorithm 密码哈希算法，慢速且加盐，用于保存用户密码，不要使用 MD5Salt
+type PasswordAlgorithm string
+
+const (
+	// PasswordArgon2id Argon2id (RFC 9106)，内存困难，推荐使用
+	PasswordArgon2id PasswordAlgorithm = "argon2id"
+	// PasswordScrypt scrypt (RFC 7914)，内存困难
+	PasswordScrypt PasswordAlgorithm = "scrypt"
+	// PasswordPBKDF2 PBKDF2-HMAC-SHA256 (RFC 8018)，需要 FIPS 合规时使用
+	PasswordPBKDF2 PasswordAlgorithm = "pbkdf2-sha256"
+)
+
+// PasswordOptions 密码哈希的参数，零值字段使用默认值，默认值为 OWASP 推荐的最低参数
+// 提高参数后 PasswordNeedsRehash 对旧的哈希返回 true，用户登录时重新计算
+// HashPassword 和 VerifyPassword 拒绝使用超过 1GiB 内存的参数，Argon2Memory 和 128*ScryptR*ScryptN*ScryptP 不能超过这个值，
+// Argon2Time 不能超过 100，PBKDF2Iterations 不能超过 1e8，SaltLength 和 KeyLength 不能为负数
+type PasswordOptions struct {
+	// 算法 [PasswordArgon2id]
+	Algorithm PasswordAlgorithm
+	// Argon2id 的迭代次数 [2]
+	Argon2Time uint32
+	// Argon2id 使用的内存，单位 KiB [19456，即 19MiB]
+	Argon2Memory uint32
+	// Argon2id 的并行度 [1]
+	Argon2Threads uint8
+	// scrypt 的 CPU/内存开销 N，必须是 2 的幂 [131072]
+	ScryptN int
+	// scrypt 的块大小 r [8]
+	ScryptR int
+	// scrypt 的并行度 p [1]
+	ScryptP int
+	// PBKDF2 的迭代次数 [600000]
+	PBKDF2Iterations int
+	// 盐的长度 [16]
+	SaltLength int
+	// 哈希的长度 [32]
+	KeyLength int
+}
+
+var (
+	// ErrPasswordHashFormat 不是 HashPassword 生成的 PHC 格式字符串
+	ErrPasswordHashFormat = errors.New("encrypt: invalid password hash format")
+)
+
+// passwordMaxMemory 验证密码时允许哈希使用的内存上限，单位字节
+const passwordMaxMemory = 1 << 30
+
+// passwordHash 解析后的 PHC 格式字符串: $算法$参数$盐$哈希
+type passwordHash struct {
+	opt  PasswordOptions
+	salt []byte
+	key  []byte
+}
+
+func (opt *PasswordOptions) withDefaults() PasswordOptions {
+	o := PasswordOptions{}
+	if opt != nil {
+		o = *opt
+	}
+	if o.Algorithm == "" {
+		o.Algorithm = PasswordArgon2id
+	}
+	if o.Argon2Time == 0 {
+		o.Argon2Time = 2
+	}
+	if o.Argon2Memory == 0 {
+		o.Argon2Memory = 19 * 1024
+	}
+	if o.Argon2Threads == 0 {
+		o.Argon2Threads = 1
+	}
+	if o.ScryptN == 0 {
+		o.ScryptN = 1 << 17
+	}
+	if o.ScryptR == 0 {
+		o.ScryptR = 8
+	}
+	if o.ScryptP == 0 {
+		o.ScryptP = 1
+	}
+	if o.PBKDF2Iterations == 0 {
+		o.PBKDF2Iterations = 600000
+	}
+	if o.SaltLength == 0 {
+		o.SaltLength = 16
+	}
+	if o.KeyLength == 0 {
+		o.KeyLength = 32
+	}
+	return o
+}
+
+// validate 检查参数的范围，HashPassword 和 VerifyPassword 使用相同的上限，
+// 避免生成无法验证的哈希，也避免篡改的哈希耗尽 CPU 和内存：
+// 内存不超过 passwordMaxMemory，Argon2id 的 m 单位为 KiB，scrypt 每次计算的内存为 128*r*N，p 次合计 128*r*N*p
+func (o PasswordOptions) validate() error {
+	if o.SaltLength <= 0 || o.KeyLength <= 0 {
+		return errors.New("encrypt: salt and key length must be positive")
+	}
+	switch o.Algorithm {
+	case PasswordArgon2id:
+		if o.Argon2Time > 100 || o.Argon2Memory > passwordMaxMemory/1024 {
+			return errors.New("encrypt: argon2id time must be at most 100 and memory at most 1GiB")
+		}
+	case PasswordScrypt:
+		if o.ScryptN < 2 || o.ScryptN&(o.ScryptN-1) != 0 {
+			return errors.New("encrypt: scrypt N must be a power of 2")
+		}
+		// 先限制 r 和 p，乘积不会溢出
+		if o.ScryptR <= 0 || o.ScryptP <= 0 || o.ScryptR >= 1<<20 || o.ScryptP >= 1<<20 || o.ScryptR*o.ScryptP >= 1<<20 ||
+			int64(o.ScryptN) > passwordMaxMemory/(128*int64(o.ScryptR)*int64(o.ScryptP)) {
+			return errors.New("encrypt: scrypt r*p must be less than 2^20 and memory 128*r*N*p at most 1GiB")
+		}
+	case PasswordPBKDF2:
+		if o.PBKDF2Iterations <= 0 || o.PBKDF2Iterations > 100000000 {
+			return errors.New("encrypt: PBKDF2 iterations must be 1 to 100000000")
+		}
+	default:
+		return fmt.Errorf("encrypt: unsupported password algorithm %s", o.Algorithm)
+	}
+	return nil
+}
+
+func (h *passwordHash) derive(password []byte) ([]byte, error) {
+	o := h.opt
+	switch o.Algorithm {
+	case PasswordArgon2id:
+		return argon2.IDKey(password, h.salt, o.Argon2Time, o.Argon2Memory, o.Argon2Threads, uint32(o.KeyLength)), nil
+	case PasswordScrypt:
+		return scrypt.Key(password, h.salt, o.ScryptN, o.ScryptR, o.ScryptP, o.KeyLength)
+	case PasswordPBKDF2:
+		return pbkdf2.Key(password, h.salt, o.PBKDF2Iterations, o.KeyLength, sha256.New), nil
+	}
+	return nil, fmt.Errorf("encrypt: unsupported password algorithm %s", o.Algorithm)
+}
+
+// String 编码为 PHC 格式，如 $argon2id$v=19$m=19456,t=2,p=1$盐$哈希，盐和哈希为无填充的 base64
+func (h *passwordHash) String() string {
+	o := h.opt
+	var params string
+	switch o.Algorithm {
+	case PasswordArgon2id:
+		params = fmt.Sprintf("v=%d$m=%d,t=%d,p=%d", argon2.Version, o.Argon2Memory, o.Argon2Time, o.Argon2Threads)
+	case PasswordScrypt:
+		params = fmt.Sprintf("ln=%d,r=%d,p=%d", bits.TrailingZeros(uint(o.ScryptN)), o.ScryptR, o.ScryptP)
+	case PasswordPBKDF2:
+		params = fmt.Sprintf("i=%d", o.PBKDF2Iterations)
+	}
+	enc := base64.RawStdEncoding
+	return "$" + string(o.Algorithm) + "$" + params + "$" + enc.EncodeToString(h.salt) + "$" + enc.EncodeToString(h.key)
+}
+
+func parsePasswordHash(encoded string) (*passwordHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return nil, ErrPasswordHashFormat
+	}
+
+	h := &passwordHash{}
+	h.opt.Algorithm = PasswordAlgorithm(parts[1])
+	params := parts[2]
+
+	switch h.opt.Algorithm {
+	case PasswordArgon2id:
+		if len(parts) != 6 || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+			return nil, ErrPasswordHashFormat
+		}
+		params = parts[3]
+	default:
+		if len(parts) != 5 {
+			return nil, ErrPasswordHashFormat
+		}
+	}
+
+	values := map[string]int{}
+	for _, param := range strings.Split(params, ",") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrPasswordHashFormat
+		}
+		v, err := strconv.Atoi(kv[1])
+		if err != nil || v <= 0 {
+			return nil, ErrPasswordHashFormat
+		}
+		values[kv[0]] = v
+	}
+
+	// 参数必须能转换为 PasswordOptions 的类型，范围由 validate 检查
+	switch h.opt.Algorithm {
+	case PasswordArgon2id:
+		if values["m"] > math.MaxUint32 || values["t"] > math.MaxUint32 || values["p"] > math.MaxUint8 ||
+			values["m"] == 0 || values["t"] == 0 || values["p"] == 0 {
+			return nil, ErrPasswordHashFormat
+		}
+		h.opt.Argon2Memory = uint32(values["m"])
+		h.opt.Argon2Time = uint32(values["t"])
+		h.opt.Argon2Threads = uint8(values["p"])
+	case PasswordScrypt:
+		if values["ln"] == 0 || values["ln"] > 30 {
+			return nil, ErrPasswordHashFormat
+		}
+		h.opt.ScryptN = 1 << uint(values["ln"])
+		h.opt.ScryptR = values["r"]
+		h.opt.ScryptP = values["p"]
+	case PasswordPBKDF2:
+		h.opt.PBKDF2Iterations = values["i"]
+	}
+
+	var err error
+	enc := base64.RawStdEncoding
+	if h.salt, err = enc.DecodeString(parts[len(parts)-2]); err != nil || len(h.salt) == 0 {
+		return nil, ErrPasswordHashFormat
+	}
+	if h.key, err = enc.DecodeString(parts[len(parts)-1]); err != nil || len(h.key) == 0 {
+		return nil, ErrPasswordHashFormat
+	}
+	h.opt.SaltLength, h.opt.KeyLength = len(h.salt), len(h.key)
+
+	if h.opt.validate() != nil {
+		return nil, ErrPasswordHashFormat
+	}
+
+	return h, nil
+}
+
+// HashPassword 计算密码的哈希，返回包含算法、参数和随机盐的 PHC 格式字符串，opt 为 nil 时使用 Argon2id
+func (instance *XPEncryptImpl) HashPassword(password string, opt *PasswordOptions) (string, error) {
+	h := &passwordHash{opt: opt.withDefaults()}
+	if err := h.opt.validate(); err != nil {
+		return "", err
+	}
+
+	h.salt = make([]byte, h.opt.SaltLength)
+	if _, err := rand.Read(h.salt); err != nil {
+		return "", err
+	}
+
+	var err error
+	if h.key, err = h.derive([]byte(password)); err != nil {
+		return "", err
+	}
+
+	return h.String(), nil
+}
+
+// VerifyPassword 验证密码，哈希的比较是常量时间的
+// 密码错误时返回 false 和 nil，encoded 格式错误时返回 ErrPasswordHashFormat
+func (instance *XPEncryptImpl) VerifyPassword(password, encoded string) (bool, error) {
+	h, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := h.derive([]byte(password))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, h.key) == 1, nil
+}
+
+// PasswordNeedsRehash 判断哈希的算法或参数是否与 opt 不同，需要在密码验证通过后重新计算
+func (instance *XPEncryptImpl) PasswordNeedsRehash(encoded string, opt *PasswordOptions) bool {
+	h, err := parsePasswordHash(encoded)
+	if err != nil {
+		return true
+	}
+
+	want := opt.withDefaults()
+	if h.opt.Algorithm != want.Algorithm || h.opt.SaltLength < want.SaltLength || h.opt.KeyLength != want.KeyLength {
+		return true
+	}
+
+	switch want.Algorithm {
+	case PasswordArgon2id:
+		return h.opt.Argon2Time != want.Argon2Time || h.opt.Argon2Memory != want.Argon2Memory ||
+			h.opt.Argon2Threads != want.Argon2Threads
+	case PasswordScrypt:
+		return h.opt.ScryptN != want.ScryptN || h.opt.ScryptR != want.ScryptR || h.opt.ScryptP != want.ScryptP
+	case PasswordPBKDF2:
+		return h.opt.PBKDF2Iterations != want.PBKDF2Iterations
+	}
+	return true
+}
+
+// VerifyPasswordAndRehash 验证密码，密码正确且哈希需要更新时返回新的哈希 rehashed，调用方保存它，否则 rehashed 为空字符串
+func (instance *XPEncryptImpl) VerifyPasswordAndRehash(password, encoded string, opt *PasswordOptions) (ok bool, rehashed string, err error) {
+	if ok, err = instance.VerifyPassword(password, encoded); !ok || err != nil {
+		return false, "", err
+	}
+
+	if instance.PasswordNeedsRehash(encoded, opt) {
+		if rehashed, err = instance.HashPassword(password, opt); err != nil {
+			return true, "", err
+		}
+	}
+
+	return true, rehashed, nil
+}
+
+// HKDF 使用 HKDF-SHA256 (RFC 5869) 从 secret 派生 length 字节的子密钥
+// secret 必须是高熵的密钥而不是密码，info 区分不同用途的子密钥，如 "encrypt" 和 "sign"，salt 可以为 nil
+func (instance *XPEncryptImpl) HKDF(secret, salt, info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*sha256.Size {
+		return nil, fmt.Errorf("encrypt: HKDF length must be 1 to %d", 255*sha256.Size)
+	}
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+/*********************** Password ********************/
+
+/*********************** SM3 ********************/
+/**
+* SM3 国密杂凑算法 (GB/T 32905)
+* @param str string 需要计算的字符串
+* @param to_upper bool 返回类型 true大写 false小写
+ */
+func Sm3String(str string, toUpper bool) string {
+	if toUpper {
+		return fmt.Sprintf("%X", sm3.Sm3Sum([]byte(str)))
+	}
+	return fmt.Sprintf("%x", sm3.Sm3Sum([]byte(str)))
+}
+
+// SM3 返回 data 的 SM3 摘要，32 字节
+func (instance *XPEncryptImpl) SM3(data []byte) []byte {
+	return sm3.Sm3Sum(data)
+}
+
+// NewSM3 返回 SM3 的 hash.Hash，用于流式计算和 hmac.New(stl.NewSM3, key)
+func NewSM3() hash.Hash {
+	return sm3.New()
+}
+/*********************** SM3 ********************/
+
+/*********************** SM4 ********************/
+// SM4Encrypt SM4 国密分组密码 (GB/T 32907) CBC 模式加密，与 AESEncrypt 相同使用 PKCS5 填充，返回 base64
+// key 和 iv 为 16 字节，新代码应使用带认证的 SM4GCMEncrypt
+func (instance *XPEncryptImpl) SM4Encrypt(origData, key, iv []byte) (string, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != block.BlockSize() {
+		return "", errors.New("encrypt: IV length must equal block size")
+	}
+	origData = PKCS5Padding(origData, block.BlockSize())
+
+	blockMode := cipher.NewCBCEncrypter(block, iv)
+	crypt := make([]byte, len(origData))
+	blockMode.CryptBlocks(crypt, origData)
+	return base64.StdEncoding.EncodeToString(crypt), nil
+}
+
+// SM4Decrypt 解密 SM4Encrypt 的密文，填充错误时返回错误
+func (instance *XPEncryptImpl) SM4Decrypt(crypt string, key, iv []byte) (string, error) {
+	decodeData, err := base64.StdEncoding.DecodeString(crypt)
+	if err != nil {
+		return "", err
+	}
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	blockSize := block.BlockSize()
+	if len(iv) != blockSize {
+		return "", errors.New("encrypt: IV length must equal block size")
+	}
+	if len(decodeData) == 0 || len(decodeData)%blockSize != 0 {
+		return "", errors.New("encrypt: ciphertext is not a multiple of the block size")
+	}
+
+	blockMode := cipher.NewCBCDecrypter(block, iv)
+	origData := make([]byte, len(decodeData))
+	blockMode.CryptBlocks(origData, decodeData)
+	if padding := int(origData[len(origData)-1]); padding == 0 || padding > blockSize {
+		return "", errors.New("encrypt: invalid padding")
+	}
+	origData = PKCS5UnPadding(origData)
+
+	return string(origData), nil
+}
+
+// SM4GCMEncrypt SM4-GCM 认证加密，返回 nonce(12字节) + 密文 + tag(16字节)，key 为 16 字节
+func (instance *XPEncryptImpl) SM4GCMEncrypt(plaintext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(AEADSM4GCM, key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadSeal(aead, nil, plaintext, additionalData)
+}
+
+// SM4GCMDecrypt 解密 SM4GCMEncrypt 的密文，认证失败时返回 ErrAEADAuthentication
+func (instance *XPEncryptImpl) SM4GCMDecrypt(ciphertext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(AEADSM4GCM, key)
+	if err != nil {
+		return nil, err
+	}
+	return aeadOpen(aead, ciphertext, additionalData)
+}
+/*********************** SM4 ********************/
+
+/*********************** SM2 ********************/
+// sm2CiphertextMinSize 0x04 + C1(64字节) + C3(32字节)
+const sm2CiphertextMinSize = 1 + 64 + 32
+
+/**
+ * 获取 SM2 公钥
+ * @param file_path string 公钥路径，PKIX 格式的 PEM ex: ./conf/keys/sm2_public_key.pem
+ */
+func GetSM2PublicKey(filePath string) (*sm2.PublicKey, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSM2PublicKey(data)
+}
+
+/**
+ * 获取 SM2 私钥
+ * @param file_path string 私钥路径，PKCS#8 (openssl genpkey -algorithm SM2) 或 SEC1 格式的 PEM
+ * @param pwd []byte 加密的 PKCS#8 私钥的密码，未加密时为 nil
+ */
+func GetSM2PrivateKey(filePath string, pwd []byte) (*sm2.PrivateKey, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSM2PrivateKey(data, pwd)
+}
+
+// ParseSM2PublicKey 解析 PEM 格式的 SM2 公钥
+func ParseSM2PublicKey(pemData []byte) (*sm2.PublicKey, error) {
+	return sm2.ReadPublicKeyFromMem(pemData, nil)
+}
+
+// ParseSM2PrivateKey 解析 PEM 格式的 SM2 私钥，PKCS#8 (PRIVATE KEY, ENCRYPTED PRIVATE KEY) 或 SEC1 (EC PRIVATE KEY)
+func ParseSM2PrivateKey(pemData, pwd []byte) (*sm2.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("encrypt: failed to decode SM2 private key PEM")
+	}
+	if block.Type == "EC PRIVATE KEY" {
+		return sm2.ParseSm2PrivateKey(block.Bytes)
+	}
+	return sm2.ParsePKCS8PrivateKey(block.Bytes, pwd)
+}
+
+// SM2Sign SM2 签名 (GB/T 32918)，返回 ASN.1 DER 编码的 (r, s)
+// uid 为签名者的标识，参与计算 Z 值，为 nil 时使用标准的默认值 "1234567812345678"
+func (instance *XPEncryptImpl) SM2Sign(priv *sm2.PrivateKey, data, uid []byte) ([]byte, error) {
+	r, s, err := sm2.Sm2Sign(priv, data, uid)
+	if err != nil {
+		return nil, err
+	}
+	return sm2.SignDigitToSignData(r, s)
+}
+
+// SM2Verify 验证 SM2Sign 的签名，uid 必须与签名时相同
+func (instance *XPEncryptImpl) SM2Verify(pub *sm2.PublicKey, data, sign, uid []byte) error {
+	r, s, err := sm2.SignDataToSignDigit(sign)
+	if err != nil {
+		return err
+	}
+	if !sm2.Sm2Verify(pub, data, uid, r, s) {
+		return errors.New("encrypt: SM2 verification failed")
+	}
+	return nil
+}
+
+// SM2Encrypt SM2 公钥加密，密文为 0x04 + C1 + C3 + C2 (GM/T 0003-2012 的顺序)
+func (instance *XPEncryptImpl) SM2Encrypt(pub *sm2.PublicKey, data []byte) ([]byte, error) {
+	return sm2.Encrypt(pub, data)
+}
+
+// SM2Decrypt 解密 SM2Encrypt 的密文，C3 校验失败时返回错误
+func (instance *XPEncryptImpl) SM2Decrypt(priv *sm2.PrivateKey, data []byte) ([]byte, error) {
+	if len(data) < sm2CiphertextMinSize || data[0] != 0x04 {
+		return nil, errors.New("encrypt: invalid SM2 ciphertext")
+	}
+	// GB/T 32918.4 B1：C1 必须是曲线上的点，sm2.Decrypt 不检查，无效曲线上的点会泄露私钥
+	x1, y1 := new(big.Int).SetBytes(data[1:33]), new(big.Int).SetBytes(data[33:65])
+	if !priv.Curve.IsOnCurve(x1, y1) {
+		return nil, errors.New("encrypt: invalid SM2 ciphertext")
+	}
+	plaintext, err := sm2.Decrypt(priv, data)
+	if err != nil {
+		return nil, errors.New("encrypt: SM2 decryption failed")
+	}
+	return plaintext, nil
+}
+/*********************** SM2 ********************/
@@ -0,0 +1,332 @@
+package stl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/tjfoc/gmsm/sm2"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func mustBigInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex integer %s", s)
+	}
+	return n
+}
+
+func encryptTestStream(t *testing.T, alg AEADAlgorithm, key, plaintext, additionalData []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encrypt.EncryptStream(&buf, bytes.NewReader(plaintext), alg, key, additionalData); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptTestStream(stream, key, additionalData []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := Encrypt.DecryptStream(&buf, bytes.NewReader(stream), key, additionalData)
+	return buf.Bytes(), err
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	keys := map[AEADAlgorithm][]byte{
+		AEADAESGCM:           bytes.Repeat([]byte{1}, 32),
+		AEADChaCha20Poly1305: bytes.Repeat([]byte{2}, 32),
+		AEADSM4GCM:           bytes.Repeat([]byte{3}, 16),
+	}
+	for alg, key := range keys {
+		for _, size := range []int{0, 1, aeadStreamChunkSize - 1, aeadStreamChunkSize, aeadStreamChunkSize + 1, 3 * aeadStreamChunkSize} {
+			plaintext := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+			stream := encryptTestStream(t, alg, key, plaintext, []byte("file:7"))
+
+			// 头部 6+7 字节，每块 16 字节的 tag，至少有一块
+			chunks := size/aeadStreamChunkSize + 1
+			if size > 0 && size%aeadStreamChunkSize == 0 {
+				chunks--
+			}
+			if want := 13 + size + 16*chunks; len(stream) != want {
+				t.Errorf("alg %d, %d bytes: stream of %d bytes, want %d", alg, size, len(stream), want)
+			}
+
+			got, err := decryptTestStream(stream, key, []byte("file:7"))
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Errorf("alg %d, %d bytes: DecryptStream = %d bytes, %v", alg, size, len(got), err)
+			}
+		}
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	plaintext := bytes.Repeat([]byte{'x'}, 2*aeadStreamChunkSize+5)
+	stream := encryptTestStream(t, AEADAESGCM, key, plaintext, []byte("file:7"))
+	const header, chunk = 13, aeadStreamChunkSize + 16
+	first, second, third := stream[header:header+chunk], stream[header+chunk:header+2*chunk], stream[header+2*chunk:]
+
+	modified := func(i int) []byte {
+		s := append([]byte(nil), stream...)
+		s[i] ^= 1
+		return s
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(append([][]byte{stream[:header]}, parts...), nil)
+	}
+
+	for _, c := range []struct {
+		name           string
+		stream         []byte
+		additionalData string
+		want           error
+	}{
+		{"header only", stream[:header], "file:7", ErrAEADTruncated},
+		{"truncated header", stream[:10], "file:7", ErrAEADFormat},
+		{"truncated at a chunk boundary", stream[:header+2*chunk], "file:7", ErrAEADAuthentication},
+		{"truncated in a chunk", stream[:len(stream)-1], "file:7", ErrAEADAuthentication},
+		{"dropped chunk", join(first, third), "file:7", ErrAEADAuthentication},
+		{"reordered chunks", join(second, first, third), "file:7", ErrAEADAuthentication},
+		{"appended chunk", join(first, second, third, third), "file:7", ErrAEADAuthentication},
+		{"version", modified(0), "file:7", ErrAEADFormat},
+		// ChaCha20-Poly1305 的密钥也是 32 字节，算法记录在认证的头部
+		{"alg", append([]byte{stream[0], byte(AEADChaCha20Poly1305)}, stream[2:]...), "file:7", ErrAEADAuthentication},
+		{"unknown alg", append([]byte{stream[0], 9}, stream[2:]...), "file:7", ErrAEADAlgorithm},
+		{"chunk size", modified(5), "file:7", ErrAEADAuthentication},
+		{"nonce prefix", modified(8), "file:7", ErrAEADAuthentication},
+		{"ciphertext", modified(header + chunk + 100), "file:7", ErrAEADAuthentication},
+		{"additional data", stream, "file:8", ErrAEADAuthentication},
+	} {
+		if _, err := decryptTestStream(c.stream, key, []byte(c.additionalData)); !errors.Is(err, c.want) {
+			t.Errorf("%s: DecryptStream = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	for _, alg := range []AEADAlgorithm{AEADAESGCM, AEADChaCha20Poly1305} {
+		envelope, err := Encrypt.SealWithKeyID(alg, "k1", key, []byte("secret"), []byte("user:4"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, err := Encrypt.EnvelopeKeyID(envelope); err != nil || id != "k1" {
+			t.Errorf("EnvelopeKeyID = %q, %v", id, err)
+		}
+		if plain, err := Encrypt.Open(key, envelope, []byte("user:4")); err != nil || string(plain) != "secret" {
+			t.Fatalf("Open = %q, %v", plain, err)
+		}
+
+		modified := func(i int, b byte) []byte {
+			e := append([]byte(nil), envelope...)
+			e[i] = b
+			return e
+		}
+		for _, c := range []struct {
+			name           string
+			envelope       []byte
+			additionalData string
+			want           error
+		}{
+			{"version", modified(0, 2), "user:4", ErrAEADFormat},
+			// AES-GCM 与 ChaCha20-Poly1305 的密钥都是 32 字节，算法记录在认证的头部
+			{"alg", modified(1, byte(AEADAESGCM+AEADChaCha20Poly1305-alg)), "user:4", ErrAEADAuthentication},
+			{"unknown alg", modified(1, 9), "user:4", ErrAEADAlgorithm},
+			{"key ID", modified(3, 'k'+1), "user:4", ErrAEADAuthentication},
+			{"key ID length", modified(2, 255), "user:4", ErrAEADFormat},
+			{"ciphertext", modified(len(envelope)-1, envelope[len(envelope)-1]^1), "user:4", ErrAEADAuthentication},
+			{"truncated", envelope[:len(envelope)-20], "user:4", ErrAEADFormat},
+			{"additional data", envelope, "user:5", ErrAEADAuthentication},
+		} {
+			if _, err := Encrypt.Open(key, c.envelope, []byte(c.additionalData)); !errors.Is(err, c.want) {
+				t.Errorf("alg %d, %s: Open = %v, want %v", alg, c.name, err, c.want)
+			}
+		}
+	}
+}
+
+func TestVerifyPasswordBounds(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	for _, encoded := range []string{
+		// 128*r*N = 2^57 bytes
+		"$scrypt$ln=24,r=1048575,p=1$" + salt + "$" + key,
+		// 128*r*N*p = 2 GiB
+		"$scrypt$ln=20,r=8,p=2$" + salt + "$" + key,
+		"$scrypt$ln=25,r=1,p=1$" + salt + "$" + key,
+		// m = 2 GiB
+		"$argon2id$v=19$m=2097152,t=1,p=1$" + salt + "$" + key,
+	} {
+		if ok, err := Encrypt.VerifyPassword("x", encoded); ok || !errors.Is(err, ErrPasswordHashFormat) {
+			t.Errorf("VerifyPassword(%s) = %v, %v, want ErrPasswordHashFormat", encoded, ok, err)
+		}
+	}
+
+	for _, opt := range []*PasswordOptions{
+		{Algorithm: PasswordArgon2id, Argon2Memory: 1024},
+		{Algorithm: PasswordScrypt, ScryptN: 1 << 10},
+		{Algorithm: PasswordPBKDF2, PBKDF2Iterations: 1000},
+	} {
+		encoded, err := Encrypt.HashPassword("secret", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := Encrypt.VerifyPassword("secret", encoded); !ok || err != nil {
+			t.Errorf("VerifyPassword(%s) = %v, %v", encoded, ok, err)
+		}
+		if ok, err := Encrypt.VerifyPassword("wrong", encoded); ok || err != nil {
+			t.Errorf("VerifyPassword of a wrong password with %s = %v, %v", encoded, ok, err)
+		}
+	}
+}
+
+func TestHashPasswordBounds(t *testing.T) {
+	for _, opt := range []*PasswordOptions{
+		{Algorithm: PasswordArgon2id, Argon2Time: 101, Argon2Memory: 64},
+		{Algorithm: PasswordArgon2id, Argon2Memory: 2 << 20},
+		{Algorithm: PasswordScrypt, ScryptN: 1 << 20, ScryptR: 8, ScryptP: 2},
+		{Algorithm: PasswordScrypt, ScryptN: 1 << 10, ScryptR: 1 << 40, ScryptP: 1 << 40},
+		{Algorithm: PasswordScrypt, ScryptN: 1000},
+		{Algorithm: PasswordPBKDF2, PBKDF2Iterations: 100000001},
+		{Algorithm: PasswordPBKDF2, PBKDF2Iterations: -1},
+		{Algorithm: PasswordPBKDF2, PBKDF2Iterations: 1000, SaltLength: -1},
+		{Algorithm: PasswordArgon2id, Argon2Memory: 64, KeyLength: -1},
+		{Algorithm: "bcrypt"},
+	} {
+		if encoded, err := Encrypt.HashPassword("secret", opt); err == nil {
+			t.Errorf("HashPassword(%+v) = %s, want an error", *opt, encoded)
+		}
+	}
+
+	// the bounds are inclusive
+	encoded, err := Encrypt.HashPassword("secret", &PasswordOptions{Algorithm: PasswordArgon2id, Argon2Time: 100, Argon2Memory: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Encrypt.VerifyPassword("secret", encoded); !ok || err != nil {
+		t.Errorf("VerifyPassword(%s) = %v, %v", encoded, ok, err)
+	}
+}
+
+func TestHKDFLength(t *testing.T) {
+	for _, length := range []int{-1, 0, 255*32 + 1} {
+		if key, err := Encrypt.HKDF([]byte("secret"), nil, nil, length); err == nil {
+			t.Errorf("HKDF of %d bytes = %x, want an error", length, key)
+		}
+	}
+	key, err := Encrypt.HKDF([]byte("secret"), nil, nil, 255*32)
+	if err != nil || len(key) != 255*32 {
+		t.Errorf("HKDF of %d bytes = %d bytes, %v", 255*32, len(key), err)
+	}
+}
+
+// GB/T 32905 附录 A 的示例
+func TestSM3Vectors(t *testing.T) {
+	for _, v := range []struct{ in, sum string }{
+		{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		{strings.Repeat("abcd", 16), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+	} {
+		if got := Sm3String(v.in, false); got != v.sum {
+			t.Errorf("Sm3String(%q) = %s, want %s", v.in, got, v.sum)
+		}
+		if got := hex.EncodeToString(Encrypt.SM3([]byte(v.in))); got != v.sum {
+			t.Errorf("SM3(%q) = %s, want %s", v.in, got, v.sum)
+		}
+	}
+}
+
+// GB/T 32907 附录 A 的示例 1，零 IV 的 CBC 第一个分组与 ECB 相同
+func TestSM4Vector(t *testing.T) {
+	key := mustHex(t, "0123456789abcdeffedcba9876543210")
+	crypt, err := Encrypt.SM4Encrypt(key, key, make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(crypt)
+	if got := hex.EncodeToString(data[:16]); got != "681edf34d206965e86b3e94f536e4246" {
+		t.Errorf("SM4 of the example = %s, want 681edf34d206965e86b3e94f536e4246", got)
+	}
+	plain, err := Encrypt.SM4Decrypt(crypt, key, make([]byte, 16))
+	if err != nil || plain != string(key) {
+		t.Errorf("SM4Decrypt = %x, %v", plain, err)
+	}
+}
+
+// RFC 8998 附录 A.1 的 SM4-GCM 示例
+func TestSM4GCMVector(t *testing.T) {
+	key := mustHex(t, "0123456789abcdeffedcba9876543210")
+	ciphertext := append(mustHex(t, "00001234567800000000abcd"), mustHex(t,
+		"17f399f08c67d5ee19d0dc9969c4bb7d5fd46fd3756489069157b282bb200735"+
+			"d82710ca5c22f0ccfa7cbf93d496ac15a56834cbcf98c397b4024a2691233b8d"+
+			"83de3541e4c2b58177e065a9bf7b62ec")...)
+	aad := mustHex(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	want := "aaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbccccccccccccccccdddddddddddddddd" +
+		"eeeeeeeeeeeeeeeeffffffffffffffffeeeeeeeeeeeeeeeeaaaaaaaaaaaaaaaa"
+
+	plain, err := Encrypt.SM4GCMDecrypt(ciphertext, key, aad)
+	if err != nil || hex.EncodeToString(plain) != want {
+		t.Fatalf("SM4GCMDecrypt = %x, %v, want %s", plain, err, want)
+	}
+	ciphertext[20] ^= 1
+	if _, err = Encrypt.SM4GCMDecrypt(ciphertext, key, aad); !errors.Is(err, ErrAEADAuthentication) {
+		t.Errorf("SM4GCMDecrypt of a modified ciphertext = %v, want ErrAEADAuthentication", err)
+	}
+}
+
+// GM/T 0003.5 附录 A 推荐曲线上的签名示例，默认的用户标识 1234567812345678
+func TestSM2SignVector(t *testing.T) {
+	curve := sm2.P256Sm2()
+	d := mustBigInt(t, "3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	x, y := curve.ScalarBaseMult(d.Bytes())
+	pub := &sm2.PublicKey{Curve: curve, X: x, Y: y}
+	if x.Cmp(mustBigInt(t, "09F9DF311E5421A150DD7D161E4BC5C672179FAD1833FC076BB08FF356F35020")) != 0 ||
+		y.Cmp(mustBigInt(t, "CCEA490CE26775A52DC6EA718CC1AA600AED05FBF35E084A6632F6072DA9AD13")) != 0 {
+		t.Fatalf("public key of the example = %X, %X", x, y)
+	}
+
+	sign, err := sm2.SignDigitToSignData(
+		mustBigInt(t, "F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3"),
+		mustBigInt(t, "B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Encrypt.SM2Verify(pub, []byte("message digest"), sign, nil); err != nil {
+		t.Errorf("SM2Verify of the example: %v", err)
+	}
+	if err = Encrypt.SM2Verify(pub, []byte("message digesT"), sign, nil); err == nil {
+		t.Error("SM2Verify of another message succeeded")
+	}
+}
+
+func TestSM2DecryptInvalidPoint(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt.SM2Encrypt(&priv.PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := Encrypt.SM2Decrypt(priv, ciphertext); err != nil || string(plain) != "secret" {
+		t.Fatalf("SM2Decrypt = %q, %v", plain, err)
+	}
+
+	// C1 不在曲线上，在 sm2.Decrypt 之前拒绝
+	ciphertext[64] ^= 1
+	if _, err = Encrypt.SM2Decrypt(priv, ciphertext); err == nil || err.Error() != "encrypt: invalid SM2 ciphertext" {
+		t.Errorf("SM2Decrypt with C1 off the curve = %v, want encrypt: invalid SM2 ciphertext", err)
+	}
+}
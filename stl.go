@@ -15,11 +15,14 @@ import (
 	"github.com/xpsuper/stl/linq"
 	"github.com/xpsuper/stl/memorycache"
 	"github.com/xpsuper/stl/objassigner"
+	"github.com/xpsuper/stl/rules"
 	"github.com/xpsuper/stl/srvmanager"
 	"github.com/xpsuper/stl/taskbus"
 	"image"
 	"io"
+	"net/http"
 	"reflect"
+	"time"
 	"unsafe"
 )
 
@@ -37,7 +40,8 @@ var (
 	Regexp    *XPRegexpImpl
 	Scheduler *XPSchedulerImpl
 	Zip       *XPZipImpl
-	Jwt       *jwt.XPJwtImpl
+	Jwt       *jwt.XPJwtImpl // 兼容旧版本的 token 格式（exp 相对 iat），其他 JWT 库无法验证
+	JwtRFC    *jwt.XPJwtImpl // RFC 7519 格式，与其他 JWT 库互通，新代码应使用它
 	IdCard    *XPIdCardImpl
 )
 
@@ -56,6 +60,7 @@ func init() {
 	Scheduler = NewScheduler()
 	Zip = &XPZipImpl{}
 	Jwt = &jwt.XPJwtImpl{}
+	JwtRFC = jwt.NewJwt()
 	IdCard = &XPIdCardImpl{}
 }
 
@@ -379,6 +384,16 @@ func EvalFull(extensions ...eval.Language) eval.Language {
 	return eval.Full(extensions...)
 }
 
+// EvalStdlib 标准函数库，作为扩展使用：EvalFull(EvalStdlib())
+func EvalStdlib() eval.Language {
+	return eval.Stdlib()
+}
+
+// EvalSandbox 限制表达式的运算次数、嵌套深度、结果大小和执行时间，超出时返回 eval.ErrLimitExceeded
+func EvalSandbox(limits eval.Limits) eval.Language {
+	return eval.Sandbox(limits)
+}
+
 func EvalConstant(name string, value interface{}) eval.Language {
 	return eval.Constant(name, value)
 }
@@ -387,6 +402,25 @@ func EvalFunction(name string, value interface{}) eval.Language {
 	return eval.Function(name, value)
 }
 
+// RuleEngine 规则引擎，language 为空时使用 EvalFull(EvalStdlib())
+func RuleEngine(language ...eval.Language) *rules.Engine {
+	return rules.NewEngine(language...)
+}
+
+// JwtRemoteKeySet 远程 JWKS，使用 XPHttpImpl 获取并缓存 ttl，用作 JwtVerifyOption.KeyResolver
+func JwtRemoteKeySet(url string, ttl time.Duration) *jwt.JwtRemoteKeySet {
+	return jwt.NewRemoteKeySet(url, ttl, func(u string) ([]byte, error) {
+		resp, _, body, errs := NewHttp().Timeout(10 * time.Second).Get(u).EndBytes()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwt: GET %s: %s", u, resp.Status)
+		}
+		return body, nil
+	})
+}
+
 // HtmlParser 解析html
 func HtmlParser(r io.Reader) (*htmlparser.Node, error) {
 	return htmlparser.Parse(r)
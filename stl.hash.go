@@ -2,6 +2,8 @@ package stl
 
 import (
 	"hash/crc32"
+	"hash/fnv"
+	"math"
 	"sort"
 	"strconv"
 )
@@ -9,6 +11,21 @@ import (
 // Hash 哈希函数
 type Hash func(data []byte) uint32
 
+// NodeHash 根据Key选择节点的哈希策略
+// ConsistencyHash、JumpHash 和 RendezvousHash 均实现了该接口
+type NodeHash interface {
+	// Add 添加节点
+	Add(nodes ...string)
+	// Remove 移除节点
+	Remove(node string)
+	// Get 获取Key对应的节点
+	Get(key string) string
+	// GetN 获取Key对应的n个不同节点，用于多副本，节点不足n个时返回全部节点
+	GetN(key string, n int) []string
+	// Empty 是否没有节点
+	Empty() bool
+}
+
 // ConsistencyHash 一致性Hash
 // 注意，非线程安全，业务需要自行加锁
 type ConsistencyHash struct {
@@ -18,14 +35,46 @@ type ConsistencyHash struct {
 	// 哈希环，按照节点哈希值排序
 	ring []int
 	// 节点哈希值到真实节点字符串，哈希映射的逆过程
-	nodes map[int]string
+	// 不同虚拟节点的哈希值可能冲突，每个哈希值保存所有真实节点，后添加的在前，
+	// 与旧版本一样由最后添加的节点负责，移除它之后由之前的节点负责
+	nodes map[int][]string
+	// 虚拟节点Key的格式
+	separated bool
+	// 真实节点的权重
+	weights map[string]int
+	// 有界负载：真实节点的当前负载及负载系数
+	loads      map[string]int64
+	totalLoad  int64
+	loadFactor float64
+}
+
+// ConsistencyHashOptions 一致性Hash的配置
+type ConsistencyHashOptions struct {
+	// 每个真实节点的虚拟节点数量
+	Replicas int
+	// 哈希函数 [crc32.ChecksumIEEE]
+	Hash Hash
+	// 虚拟节点的Key使用 "节点#编号" 的格式，避免 "a" 的10号与 "a1" 的0号虚拟节点相同
+	// 默认使用兼容旧版本的 "编号节点" 格式，切换格式会让几乎所有Key换到其他节点，
+	// 已经在使用的哈希环（如分片的缓存）只能在可以接受全部重新分布时切换
+	SeparatedReplicaKey bool
 }
 
+// NewConsistencyHash 创建使用旧版本虚拟节点Key格式的一致性Hash
 func NewConsistencyHash(replicas int, fn Hash) *ConsistencyHash {
+	return NewConsistencyHashWithOptions(ConsistencyHashOptions{Replicas: replicas, Hash: fn})
+}
+
+// NewConsistencyHashWithOptions 按配置创建一致性Hash
+func NewConsistencyHashWithOptions(opt ConsistencyHashOptions) *ConsistencyHash {
 	r := &ConsistencyHash{
-		replicas: replicas,
-		hash:     fn,
-		nodes:    make(map[int]string),
+		replicas:   opt.Replicas,
+		hash:       opt.Hash,
+		separated:  opt.SeparatedReplicaKey,
+		nodes:      make(map[int][]string),
+		weights:    make(map[string]int),
+		loads:      make(map[string]int64),
+		loadFactor: 0.25,
 	}
 	if r.hash == nil {
 		r.hash = crc32.ChecksumIEEE
@@ -42,25 +91,87 @@ func (c *ConsistencyHash) Empty() bool {
 // 注意，如果加入的节点已经存在，会导致哈希环上面重复，如果不确定是否存在请使用Reset
 func (c *ConsistencyHash) Add(nodes ...string) {
 	for _, node := range nodes {
-		// 每个节点创建多个虚拟节点
-		for i := 0; i < c.replicas; i++ {
-			// 每个虚拟节点计算哈希值
-			hash := int(c.hash([]byte(strconv.Itoa(i) + node)))
+		c.addNode(node, 1)
+	}
+	// 哈希环排序
+	sort.Ints(c.ring)
+}
+
+// AddWeighted 按权重添加节点，虚拟节点数量为 replicas*weight
+// 如果节点已经存在，则替换其权重
+func (c *ConsistencyHash) AddWeighted(node string, weight int) {
+	if weight <= 0 {
+		return
+	}
+	if _, ok := c.weights[node]; ok {
+		c.Remove(node)
+	}
+	c.addNode(node, weight)
+	sort.Ints(c.ring)
+}
+
+func (c *ConsistencyHash) addNode(node string, weight int) {
+	// 每个节点创建多个虚拟节点
+	for i := 0; i < c.replicas*weight; i++ {
+		// 每个虚拟节点计算哈希值
+		hash := int(c.hash([]byte(c.replicaKey(node, i))))
+		owners, ok := c.nodes[hash]
+		if !ok {
 			// 加入哈希环
 			c.ring = append(c.ring, hash)
-			// 哈希值到真实节点字符串映射
-			c.nodes[hash] = node
 		}
+		// 哈希值到真实节点字符串映射，后添加的节点负责冲突的哈希值
+		c.nodes[hash] = append([]string{node}, owners...)
 	}
-	// 哈希环排序
-	sort.Ints(c.ring)
+	c.weights[node] += weight
+	if _, ok := c.loads[node]; !ok {
+		c.loads[node] = 0
+	}
+}
+
+// replicaKey 虚拟节点的Key
+func (c *ConsistencyHash) replicaKey(node string, i int) string {
+	if c.separated {
+		return node + "#" + strconv.Itoa(i)
+	}
+	return strconv.Itoa(i) + node
+}
+
+// Remove 从哈希环移除节点及其虚拟节点
+func (c *ConsistencyHash) Remove(node string) {
+	if _, ok := c.weights[node]; !ok {
+		return
+	}
+	ring := c.ring[:0]
+	for _, hash := range c.ring {
+		// 只移除该节点，保留哈希值冲突的其他节点
+		owners := c.nodes[hash][:0]
+		for _, owner := range c.nodes[hash] {
+			if owner != node {
+				owners = append(owners, owner)
+			}
+		}
+		if len(owners) == 0 {
+			delete(c.nodes, hash)
+			continue
+		}
+		c.nodes[hash] = owners
+		ring = append(ring, hash)
+	}
+	c.ring = ring
+	delete(c.weights, node)
+	c.totalLoad -= c.loads[node]
+	delete(c.loads, node)
 }
 
 // Reset 先清空哈希环再设置
 func (c *ConsistencyHash) Reset(nodes ...string) {
 	// 先清空
 	c.ring = nil
-	c.nodes = map[int]string{}
+	c.nodes = map[int][]string{}
+	c.weights = map[string]int{}
+	c.loads = map[string]int64{}
+	c.totalLoad = 0
 	// 再重置
 	c.Add(nodes...)
 }
@@ -72,10 +183,38 @@ func (c *ConsistencyHash) Get(key string) string {
 		return ""
 	}
 
+	// 返回哈希值对应的真实节点字符串
+	return c.nodes[c.ring[c.search(key)]][0]
+}
+
+// GetN 获取Key对应的n个不同节点，从Key的位置顺时针查找
+func (c *ConsistencyHash) GetN(key string, n int) []string {
+	if c.Empty() || n <= 0 {
+		return nil
+	}
+	if n > len(c.weights) {
+		n = len(c.weights)
+	}
+	result := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	idx := c.search(key)
+	for i := 0; i < len(c.ring) && len(result) < n; i++ {
+		for _, node := range c.nodes[c.ring[(idx+i)%len(c.ring)]] {
+			if _, ok := seen[node]; ok || len(result) == n {
+				continue
+			}
+			seen[node] = struct{}{}
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// 二分查找第一个大于等于Key哈希值的节点位置
+func (c *ConsistencyHash) search(key string) int {
 	// 计算Key哈希值
 	hash := int(c.hash([]byte(key)))
 
-	// 二分查找第一个大于等于Key哈希值的节点
 	idx := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= hash })
 
 	// 这里是特殊情况，也就是数组没有大于等于Key哈希值的节点
@@ -83,7 +222,248 @@ func (c *ConsistencyHash) Get(key string) string {
 	if idx == len(c.ring) {
 		idx = 0
 	}
+	return idx
+}
 
-	// 返回哈希值对应的真实节点字符串
-	return c.nodes[c.ring[idx]]
+// SetLoadFactor 设置有界负载系数 ε，节点的负载上限为 ceil(平均负载 * (1 + ε))，默认 0.25
+func (c *ConsistencyHash) SetLoadFactor(factor float64) {
+	if factor > 0 {
+		c.loadFactor = factor
+	}
+}
+
+// GetLeast 有界负载一致性哈希（Consistent Hashing with Bounded Loads）
+// 从Key的位置顺时针查找第一个负载未达上限的节点
+// 选中节点后需要调用 Inc 增加负载，处理完成后调用 Done 减少负载
+func (c *ConsistencyHash) GetLeast(key string) string {
+	if c.Empty() {
+		return ""
+	}
+	idx := c.search(key)
+	for i := 0; i < len(c.ring); i++ {
+		for _, node := range c.nodes[c.ring[(idx+i)%len(c.ring)]] {
+			if c.loadOK(node) {
+				return node
+			}
+		}
+	}
+	// 负载上限至少为平均负载，不会走到这里
+	return c.nodes[c.ring[idx]][0]
+}
+
+func (c *ConsistencyHash) loadOK(node string) bool {
+	// 加上即将分配的负载
+	avg := float64(c.totalLoad+1) / float64(len(c.loads))
+	limit := math.Ceil(avg * (1 + c.loadFactor))
+	return float64(c.loads[node])+1 <= limit
+}
+
+// Inc 节点负载加一
+func (c *ConsistencyHash) Inc(node string) {
+	if _, ok := c.loads[node]; !ok {
+		return
+	}
+	c.loads[node]++
+	c.totalLoad++
+}
+
+// Done 节点负载减一
+func (c *ConsistencyHash) Done(node string) {
+	if load, ok := c.loads[node]; !ok || load <= 0 {
+		return
+	}
+	c.loads[node]--
+	c.totalLoad--
+}
+
+// UpdateLoad 直接设置节点的负载
+func (c *ConsistencyHash) UpdateLoad(node string, load int64) {
+	if _, ok := c.loads[node]; !ok {
+		return
+	}
+	c.totalLoad += load - c.loads[node]
+	c.loads[node] = load
+}
+
+// Loads 获取所有节点的负载
+func (c *ConsistencyHash) Loads() map[string]int64 {
+	loads := make(map[string]int64, len(c.loads))
+	for node, load := range c.loads {
+		loads[node] = load
+	}
+	return loads
+}
+
+// JumpHash Jump一致性哈希（Lamping & Veach）
+// 无需虚拟节点，内存占用小且分布均匀，但只适合在末尾添加或移除节点，
+// 移除中间的节点会导致其后所有节点的Key重新分布
+// 注意，非线程安全，业务需要自行加锁
+type JumpHash struct {
+	nodes []string
+}
+
+func NewJumpHash(nodes ...string) *JumpHash {
+	j := &JumpHash{}
+	j.Add(nodes...)
+	return j
+}
+
+// Empty 是否没有节点
+func (j *JumpHash) Empty() bool {
+	return len(j.nodes) == 0
+}
+
+// Add 在末尾添加节点
+func (j *JumpHash) Add(nodes ...string) {
+	j.nodes = append(j.nodes, nodes...)
+}
+
+// Remove 移除节点
+func (j *JumpHash) Remove(node string) {
+	for i, n := range j.nodes {
+		if n == node {
+			j.nodes = append(j.nodes[:i], j.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get 获取Key对应的节点
+func (j *JumpHash) Get(key string) string {
+	if j.Empty() {
+		return ""
+	}
+	return j.nodes[jumpHash(hash64(key), len(j.nodes))]
+}
+
+// GetN 获取Key对应的n个不同节点，依次取Key所在节点之后的节点
+func (j *JumpHash) GetN(key string, n int) []string {
+	if j.Empty() || n <= 0 {
+		return nil
+	}
+	if n > len(j.nodes) {
+		n = len(j.nodes)
+	}
+	idx := int(jumpHash(hash64(key), len(j.nodes)))
+	result := make([]string, n)
+	for i := range result {
+		result[i] = j.nodes[(idx+i)%len(j.nodes)]
+	}
+	return result
+}
+
+func jumpHash(key uint64, buckets int) int32 {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int32(b)
+}
+
+// RendezvousHash 最高随机权重哈希（Rendezvous / HRW）
+// Key选择得分最高的节点，增删节点只影响该节点上的Key，支持节点权重
+// 查找的复杂度为 O(节点数)，适合节点数量不多的场景
+// 注意，非线程安全，业务需要自行加锁
+type RendezvousHash struct {
+	nodes   []string
+	weights map[string]float64
 }
+
+func NewRendezvousHash(nodes ...string) *RendezvousHash {
+	r := &RendezvousHash{weights: make(map[string]float64)}
+	r.Add(nodes...)
+	return r
+}
+
+// Empty 是否没有节点
+func (r *RendezvousHash) Empty() bool {
+	return len(r.nodes) == 0
+}
+
+// Add 添加权重为1的节点
+func (r *RendezvousHash) Add(nodes ...string) {
+	for _, node := range nodes {
+		r.AddWeighted(node, 1)
+	}
+}
+
+// AddWeighted 按权重添加节点，如果节点已经存在，则替换其权重
+func (r *RendezvousHash) AddWeighted(node string, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	if _, ok := r.weights[node]; !ok {
+		r.nodes = append(r.nodes, node)
+	}
+	r.weights[node] = weight
+}
+
+// Remove 移除节点
+func (r *RendezvousHash) Remove(node string) {
+	if _, ok := r.weights[node]; !ok {
+		return
+	}
+	delete(r.weights, node)
+	for i, n := range r.nodes {
+		if n == node {
+			r.nodes = append(r.nodes[:i], r.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get 获取Key对应的节点
+func (r *RendezvousHash) Get(key string) string {
+	best, bestScore := "", math.Inf(-1)
+	for _, node := range r.nodes {
+		if score := r.score(node, key); score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+// GetN 获取Key对应得分最高的n个节点
+func (r *RendezvousHash) GetN(key string, n int) []string {
+	if r.Empty() || n <= 0 {
+		return nil
+	}
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	nodes := make([]string, len(r.nodes))
+	copy(nodes, r.nodes)
+	scores := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		scores[node] = r.score(node, key)
+	}
+	sort.Slice(nodes, func(i, k int) bool { return scores[nodes[i]] > scores[nodes[k]] })
+	return nodes[:n]
+}
+
+// 加权得分 -weight / ln(h)，h 为 (0, 1) 内均匀分布的哈希值
+func (r *RendezvousHash) score(node, key string) float64 {
+	h := (float64(hash64(node+"\x00"+key)>>11) + 0.5) / (1 << 53)
+	return -r.weights[node] / math.Log(h)
+}
+
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// fnv的低位分布不够均匀，混淆一次
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+var (
+	_ NodeHash = (*ConsistencyHash)(nil)
+	_ NodeHash = (*JumpHash)(nil)
+	_ NodeHash = (*RendezvousHash)(nil)
+)
@@ -0,0 +1,71 @@
+package stl
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// legacyConsistencyHash 旧版本的哈希环：Key为 编号+节点，冲突的哈希值由最后添加的节点负责
+func legacyConsistencyHash(replicas int, nodes ...string) func(key string) string {
+	var ring []int
+	owners := map[int]string{}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			hash := int(crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + node)))
+			ring = append(ring, hash)
+			owners[hash] = node
+		}
+	}
+	sort.Ints(ring)
+	return func(key string) string {
+		hash := int(crc32.ChecksumIEEE([]byte(key)))
+		idx := sort.Search(len(ring), func(i int) bool { return ring[i] >= hash })
+		if idx == len(ring) {
+			idx = 0
+		}
+		return owners[ring[idx]]
+	}
+}
+
+func TestConsistencyHashLegacyKeys(t *testing.T) {
+	// "a" 的10号与 "a1" 的0号虚拟节点的Key相同
+	nodes := []string{"a", "a1", "b", "c"}
+	legacy := legacyConsistencyHash(50, nodes...)
+	ring := NewConsistencyHash(50, nil)
+	ring.Add(nodes...)
+
+	for i := 0; i < 10000; i++ {
+		key := "key:" + strconv.Itoa(i)
+		if got, want := ring.Get(key), legacy(key); got != want {
+			t.Fatalf("Get(%s) = %s, want %s as before", key, got, want)
+		}
+	}
+}
+
+func TestConsistencyHashCollision(t *testing.T) {
+	for _, separated := range []bool{false, true} {
+		ring := NewConsistencyHashWithOptions(ConsistencyHashOptions{Replicas: 50, SeparatedReplicaKey: separated})
+		ring.Add("a", "a1")
+		ring.Remove("a1")
+
+		// 移除 a1 不会带走 a 的虚拟节点
+		counts := map[string]int{}
+		for i := 0; i < 1000; i++ {
+			counts[ring.Get("key:"+strconv.Itoa(i))]++
+		}
+		if counts["a"] != 1000 {
+			t.Errorf("separated %v: keys after removing a1 = %v, want all on a", separated, counts)
+		}
+		if got := len(ring.ring); got != 50 {
+			t.Errorf("separated %v: %d virtual nodes of a, want 50", separated, got)
+		}
+	}
+
+	separated := NewConsistencyHashWithOptions(ConsistencyHashOptions{Replicas: 50, SeparatedReplicaKey: true})
+	separated.Add("a", "a1")
+	if got := len(separated.ring); got != 100 {
+		t.Errorf("%d virtual nodes of a and a1, want 100 without collision", got)
+	}
+}
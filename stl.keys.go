@@ -0,0 +1,521 @@
+package stl
+
+/*********调用示例********
+func main() {
+    key, _ := stl.GenerateKey(stl.KeyEd25519, 0)
+    privatePEM, _ := stl.MarshalPrivateKeyPEM(key, []byte("passphrase"))
+    authorized, _ := stl.MarshalOpenSSHPublicKey(key.Public(), "deploy@ci")
+    fingerprint, _ := stl.KeyFingerprint(key.Public()) // SHA256:...，与 ssh-keygen -lf 相同
+
+    ring := stl.NewKeyring(stl.AEADAESGCM)
+    ring.Rotate("2024-01", nil)
+    ciphertext, _ := ring.Encrypt([]byte("secret"), nil)
+    ring.Rotate("2024-07", nil)
+    plaintext, _ := ring.Decrypt(ciphertext, nil) // 使用 2024-01 的密钥
+}
+ ************************/
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/ssh"
+)
+
+// KeyType 非对称密钥的类型
+type KeyType string
+
+const (
+	KeyRSA     KeyType = "rsa"
+	KeyECDSA   KeyType = "ecdsa"
+	KeyEd25519 KeyType = "ed25519"
+)
+
+var (
+	// ErrKeyPassword 加密的私钥没有提供密码或密码错误
+	ErrKeyPassword = errors.New("encrypt: incorrect private key password")
+	// ErrKeyNotFound 密钥环中没有密文使用的密钥
+	ErrKeyNotFound = errors.New("encrypt: key not found")
+)
+
+// GenerateKey 生成私钥，RSA 的 bits 为模数长度 [3072]，ECDSA 的 bits 为曲线 P-256、P-384、P-521 [256]，Ed25519 忽略 bits
+// 返回 *rsa.PrivateKey、*ecdsa.PrivateKey 或 ed25519.PrivateKey
+func GenerateKey(keyType KeyType, bits int) (crypto.Signer, error) {
+	switch keyType {
+	case KeyRSA:
+		if bits == 0 {
+			bits = 3072
+		}
+		if bits < 2048 {
+			return nil, errors.New("encrypt: RSA keys must be at least 2048 bits")
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case KeyECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("encrypt: unsupported ECDSA curve size %d", bits)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("encrypt: unsupported key type %s", keyType)
+}
+
+/*********************** PEM ********************/
+// MarshalPrivateKeyPEM 将私钥编码为 PKCS#8 的 PEM
+// password 不为空时使用 PBES2 (PBKDF2-HMAC-SHA256 + AES-256-CBC) 加密，与 openssl pkcs8 -topk8 -v2 aes-256-cbc 相同
+func MarshalPrivateKeyPEM(key crypto.PrivateKey, password []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+	if der, err = encryptPKCS8(der, password); err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKeyPEM 解析 PEM 私钥: PKCS#1 (RSA PRIVATE KEY)、SEC1 (EC PRIVATE KEY)、PKCS#8 (PRIVATE KEY)、
+// 加密的 PKCS#8 (ENCRYPTED PRIVATE KEY) 以及旧的 Proc-Type: 4,ENCRYPTED 格式，未加密时 password 为 nil
+func ParsePrivateKeyPEM(data, password []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("encrypt: failed to decode private key PEM")
+	}
+
+	der := block.Bytes
+	var err error
+
+	// 旧的 Proc-Type: 4,ENCRYPTED 格式不安全，只用于读取已有的私钥
+	if x509.IsEncryptedPEMBlock(block) {
+		if len(password) == 0 {
+			return nil, ErrKeyPassword
+		}
+		if der, err = x509.DecryptPEMBlock(block, password); err != nil {
+			return nil, ErrKeyPassword
+		}
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case "ENCRYPTED PRIVATE KEY":
+		if len(password) == 0 {
+			return nil, ErrKeyPassword
+		}
+		if der, err = decryptPKCS8(der, password); err != nil {
+			return nil, err
+		}
+		// 密码错误时约 1/256 的概率填充正确，解密的数据不是 PKCS#8
+		if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+			return nil, ErrKeyPassword
+		}
+	default:
+		return nil, fmt.Errorf("encrypt: unsupported private key PEM type %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("encrypt: unsupported private key %T", key)
+	}
+	return signer, nil
+}
+
+// MarshalPublicKeyPEM 将公钥编码为 PKIX (PUBLIC KEY) 的 PEM
+func MarshalPublicKeyPEM(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePublicKeyPEM 解析公钥: PKIX (PUBLIC KEY)、PKCS#1 (RSA PUBLIC KEY)、证书 (CERTIFICATE)
+// 以及 OpenSSH authorized_keys 格式，如 ssh-ed25519 AAAA... user@host
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		pub, _, err := ParseOpenSSHPublicKey(data)
+		if err != nil {
+			return nil, errors.New("encrypt: failed to decode public key")
+		}
+		return pub, nil
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("encrypt: unsupported public key PEM type %s", block.Type)
+}
+/*********************** PEM ********************/
+
+/*********************** OpenSSH ********************/
+// MarshalOpenSSHPublicKey 将公钥编码为 OpenSSH authorized_keys 的一行，comment 可以为空
+func MarshalOpenSSHPublicKey(pub crypto.PublicKey, comment string) (string, error) {
+	sshKey, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(sshKey)), "\n")
+	if comment != "" {
+		line += " " + comment
+	}
+	return line, nil
+}
+
+// ParseOpenSSHPublicKey 解析 OpenSSH authorized_keys 格式的公钥，返回 *rsa.PublicKey、*ecdsa.PublicKey 或 ed25519.PublicKey
+func ParseOpenSSHPublicKey(line []byte) (pub crypto.PublicKey, comment string, err error) {
+	sshKey, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+	if err != nil {
+		return nil, "", err
+	}
+	cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, "", fmt.Errorf("encrypt: unsupported OpenSSH key type %s", sshKey.Type())
+	}
+	return cryptoKey.CryptoPublicKey(), comment, nil
+}
+
+// KeyFingerprint 返回公钥的 SHA256 指纹，如 SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s，与 ssh-keygen -lf 相同
+// 也接受私钥，返回其公钥的指纹
+func KeyFingerprint(key interface{}) (string, error) {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	sshKey, err := ssh.NewPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(sshKey), nil
+}
+/*********************** OpenSSH ********************/
+
+/*********************** PKCS#8 PBES2 ********************/
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// pkcs8Iterations PBKDF2 的迭代次数，加密私钥文件时使用
+const pkcs8Iterations = 600000
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func encryptPKCS8(der, password []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(password, salt, pkcs8Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data := PKCS7Padding(append([]byte(nil), der...), aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs8Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+}
+
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, errors.New("encrypt: only PBES2 encrypted private keys are supported")
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, errors.New("encrypt: only PBKDF2 is supported for encrypted private keys")
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+	if kdf.Iterations <= 0 || kdf.Iterations > 100000000 {
+		return nil, errors.New("encrypt: invalid PBKDF2 iterations")
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("encrypt: unsupported PBKDF2 PRF %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLength int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLength = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLength = 32
+	default:
+		return nil, fmt.Errorf("encrypt: unsupported private key cipher %s", scheme)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	data := info.EncryptedData
+	if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("encrypt: invalid encrypted private key")
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key(password, kdf.Salt, kdf.Iterations, keyLength, prf))
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// 密码错误时填充几乎总是错误的
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrKeyPassword
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, ErrKeyPassword
+		}
+	}
+	return plain[:len(plain)-padding], nil
+}
+/*********************** PKCS#8 PBES2 ********************/
+
+/*********************** Keyring ********************/
+// Keyring 版本化的对称密钥环，Encrypt 使用当前密钥并在密文中记录密钥ID (Encrypt.SealWithKeyID 的信封格式)，
+// Decrypt 根据密钥ID找到加密时的密钥，因此轮换密钥后旧的密文仍然可以解密
+// 密钥通常从 KMS 或配置中心加载，依次 Add 旧的密钥再 Rotate 到当前密钥
+type Keyring struct {
+	mu        sync.RWMutex
+	algorithm AEADAlgorithm
+	keys      map[string][]byte
+	current   string
+}
+
+// NewKeyring 创建使用 algorithm 加密的密钥环
+func NewKeyring(algorithm AEADAlgorithm) *Keyring {
+	return &Keyring{algorithm: algorithm, keys: make(map[string][]byte)}
+}
+
+func (k *Keyring) keySize() int {
+	if k.algorithm == AEADSM4GCM {
+		return 16
+	}
+	return 32
+}
+
+// Add 添加用于解密的密钥，不改变当前密钥，第一个添加的密钥成为当前密钥
+func (k *Keyring) Add(id string, key []byte) error {
+	return k.add(id, key, false)
+}
+
+// Rotate 添加密钥并作为当前密钥，key 为 nil 时生成随机密钥，返回使用的密钥以便保存
+func (k *Keyring) Rotate(id string, key []byte) ([]byte, error) {
+	if key == nil {
+		key = make([]byte, k.keySize())
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.add(id, key, true); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// add 在同一次加锁中添加密钥并切换当前密钥，并发的 Rotate 不会让较早的密钥覆盖当前密钥
+func (k *Keyring) add(id string, key []byte, current bool) error {
+	if id == "" || len(id) > 255 {
+		return errors.New("encrypt: key ID must be 1 to 255 bytes")
+	}
+	if _, err := newAEAD(k.algorithm, key); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("encrypt: key %s already exists", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	if current || k.current == "" {
+		k.current = id
+	}
+	return nil
+}
+
+// Current 返回当前密钥的ID
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Retire 删除不再需要的密钥，使用它的密文都已重新加密后调用，不能删除当前密钥
+func (k *Keyring) Retire(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if id == k.current {
+		return errors.New("encrypt: can not retire the current key")
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+func (k *Keyring) key(id string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Encrypt 使用当前密钥加密
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	k.mu.RLock()
+	id, key := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+
+	if id == "" {
+		return nil, ErrKeyNotFound
+	}
+	return Encrypt.SealWithKeyID(k.algorithm, id, key, plaintext, additionalData)
+}
+
+// Decrypt 使用密文记录的密钥解密，密钥已删除或不在密钥环中时返回 ErrKeyNotFound
+func (k *Keyring) Decrypt(envelope, additionalData []byte) ([]byte, error) {
+	id, err := Encrypt.EnvelopeKeyID(envelope)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.key(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return Encrypt.Open(key, envelope, additionalData)
+}
+
+// NeedsReencrypt 判断密文是否使用了当前密钥以外的密钥，用于轮换后逐步重新加密
+func (k *Keyring) NeedsReencrypt(envelope []byte) bool {
+	id, err := Encrypt.EnvelopeKeyID(envelope)
+	return err != nil || id != k.Current()
+}
+
+// Reencrypt 解密并使用当前密钥重新加密
+func (k *Keyring) Reencrypt(envelope, additionalData []byte) ([]byte, error) {
+	plaintext, err := k.Decrypt(envelope, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(plaintext, additionalData)
+}
+/*********************** Keyring ********************/
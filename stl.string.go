@@ -119,6 +119,7 @@ func (instance *XPStringImpl) MD5(str string) string {
 }
 
 // MD5Salt 字符串MD5值+盐值
+// 不要用于保存密码，密码使用 Encrypt.HashPassword
 func (instance *XPStringImpl) MD5Salt(str string, salt string, iteration int) string {
 	b := []byte(str)
 	s := []byte(salt)